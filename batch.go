@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxBatchSize is the maximum number of write requests DynamoDB accepts in a
+// single BatchWriteItem call.
+const maxBatchSize = 25
+
+const (
+	initialBackoff = 50 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// batchWrite submits the requests for the table as a single BatchWriteItem
+// call, and re-submits any unprocessed items with an increasing, jittered
+// backoff until they have all been written. If there are still unprocessed
+// items after --max-retries attempts, it gives up rather than dropping them.
+// It returns the write capacity units consumed across every attempt.
+func batchWrite(ctx context.Context, client dynamoDBAPI, table string, requests []types.WriteRequest) (float64, error) {
+	backoff := initialBackoff
+
+	var consumed float64
+
+	for attempt := 0; len(requests) > 0; attempt++ {
+		output, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{
+				table: requests,
+			},
+			ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+		})
+		if err != nil {
+			return consumed, err
+		}
+
+		units := capacityUnits(output.ConsumedCapacity...)
+		consumed += units
+
+		unprocessed := output.UnprocessedItems[table]
+		slog.Debug("wrote batch", "table", table, "items", len(requests)-len(unprocessed), "unprocessed", len(unprocessed), "capacity", units)
+
+		requests = unprocessed
+		if len(requests) == 0 {
+			return consumed, nil
+		}
+
+		if attempt == maxRetries {
+			return consumed, fmt.Errorf("%d items were still unprocessed after %d retries", len(requests), maxRetries)
+		}
+
+		// Spread retries out, so that concurrent writers which were
+		// throttled together do not all retry at the same moment.
+		delay := backoff/2 + rand.N(backoff/2)
+
+		slog.Debug("retrying unprocessed items", "table", table, "items", len(requests), "backoff", delay)
+
+		select {
+		case <-ctx.Done():
+			return consumed, ctx.Err()
+		case <-time.After(delay):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	return consumed, nil
+}
+
+// writeBatch is a batch of write requests for different keys, along with the
+// earlier requests for the same keys which they replaced.
+type writeBatch struct {
+	requests   []types.WriteRequest
+	superseded []types.WriteRequest
+}
+
+// batchWriter groups write requests into batches, and writes the batches
+// using a pool of concurrent workers. If any batch fails, every worker is
+// stopped and the error is returned by the next call to add or close.
+type batchWriter struct {
+	client dynamoDBAPI
+	table  string
+	schema tableMetadata
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	batches chan writeBatch
+	pending writeBatch
+	wg      sync.WaitGroup
+	once    sync.Once
+
+	// keys holds the position of each request in the pending batch by its
+	// key, since BatchWriteItem rejects a batch with the same key twice.
+	keys map[string]int
+
+	// mu serialises calls to onWrite and onWritten.
+	mu      sync.Mutex
+	onWrite func(items int, capacity float64)
+
+	// onWritten, if set, is called with each batch once it has been
+	// written. It must be set before the first call to add.
+	onWritten func(batch []types.WriteRequest)
+
+	// onError, if set, is called with each batch which could not be
+	// written, instead of stopping every worker. It must be set before the
+	// first call to add, and may be called concurrently.
+	onError func(batch []types.WriteRequest, err error)
+}
+
+// newBatchWriter starts concurrency workers writing to the table, which has
+// the key schema of schema. onWrite is called with the number of items in
+// each batch once it has been written, along with the write capacity units it
+// consumed, and is never called concurrently.
+func newBatchWriter(ctx context.Context, client dynamoDBAPI, table string, schema tableMetadata, concurrency int, onWrite func(items int, capacity float64)) *batchWriter {
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	w := &batchWriter{
+		client:  client,
+		table:   table,
+		schema:  schema,
+		ctx:     ctx,
+		cancel:  cancel,
+		batches: make(chan writeBatch),
+		keys:    map[string]int{},
+		onWrite: onWrite,
+	}
+
+	for range concurrency {
+		w.wg.Add(1)
+		go w.work()
+	}
+
+	return w
+}
+
+func (w *batchWriter) work() {
+	defer w.wg.Done()
+
+	for batch := range w.batches {
+		capacity, err := batchWrite(w.ctx, w.client, w.table, batch.requests)
+
+		// The superseded requests are reported along with the batch, as
+		// they would have been when each was written on its own.
+		requests := slices.Concat(batch.superseded, batch.requests)
+
+		if err != nil && w.onError != nil && w.ctx.Err() == nil {
+			w.onError(requests, err)
+			continue
+		}
+
+		if err != nil {
+			w.cancel(err)
+			continue
+		}
+
+		w.mu.Lock()
+		if w.onWritten != nil {
+			w.onWritten(requests)
+		}
+		w.onWrite(len(requests), capacity)
+		w.mu.Unlock()
+	}
+}
+
+// add queues the request, sending a batch to the workers once it holds
+// --batch-size requests. A request for a key which is already in the batch
+// replaces the earlier one, so that the last write wins as it would with
+// separate writes.
+func (w *batchWriter) add(request types.WriteRequest) error {
+	key, ok := w.key(request)
+	if ok {
+		if i, duplicate := w.keys[key]; duplicate {
+			w.pending.superseded = append(w.pending.superseded, w.pending.requests[i])
+			w.pending.requests[i] = request
+			return nil
+		}
+
+		w.keys[key] = len(w.pending.requests)
+	}
+
+	w.pending.requests = append(w.pending.requests, request)
+	if len(w.pending.requests) < batchSize {
+		return nil
+	}
+
+	return w.send()
+}
+
+// key returns the key of the item the request writes or deletes, and false
+// if it is missing any key attribute, which is left for DynamoDB to reject.
+func (w *batchWriter) key(request types.WriteRequest) (string, bool) {
+	var item map[string]types.AttributeValue
+	if request.PutRequest != nil {
+		item = request.PutRequest.Item
+	} else if request.DeleteRequest != nil {
+		item = request.DeleteRequest.Key
+	}
+
+	key := itemKeyAttributes(item, w.schema)
+	for _, value := range key {
+		if value == nil {
+			return "", false
+		}
+	}
+
+	return typedJSON(key), true
+}
+
+func (w *batchWriter) send() error {
+	if len(w.pending.requests) == 0 {
+		return nil
+	}
+
+	select {
+	case w.batches <- w.pending:
+		w.pending = writeBatch{}
+		clear(w.keys)
+		return nil
+	case <-w.ctx.Done():
+		return context.Cause(w.ctx)
+	}
+}
+
+// close writes any remaining requests, and waits for the workers to finish.
+// It is safe to call more than once.
+func (w *batchWriter) close() error {
+	var err error
+	w.once.Do(func() {
+		err = w.send()
+		close(w.batches)
+		w.wg.Wait()
+
+		if err == nil && w.ctx.Err() != nil {
+			err = context.Cause(w.ctx)
+		}
+
+		w.cancel(nil)
+	})
+
+	return err
+}
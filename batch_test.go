@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// unprocessedClient returns the last item of every batch as unprocessed for
+// the first throttled calls, and then writes everything.
+type unprocessedClient struct {
+	dynamoDBAPI
+
+	throttled int
+	calls     []int
+}
+
+func (c *unprocessedClient) BatchWriteItem(_ context.Context, input *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	output := &dynamodb.BatchWriteItemOutput{}
+
+	for table, requests := range input.RequestItems {
+		c.calls = append(c.calls, len(requests))
+
+		if len(c.calls) <= c.throttled {
+			output.UnprocessedItems = map[string][]types.WriteRequest{
+				table: requests[len(requests)-1:],
+			}
+		}
+	}
+
+	return output, nil
+}
+
+func putRequests(n int) []types.WriteRequest {
+	requests := make([]types.WriteRequest, n)
+	for i := range requests {
+		requests[i] = types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberN{Value: strconv.Itoa(i)},
+			}},
+		}
+	}
+
+	return requests
+}
+
+func setMaxRetries(t *testing.T, n int) {
+	previous := maxRetries
+	maxRetries = n
+	t.Cleanup(func() { maxRetries = previous })
+}
+
+func TestBatchWriteRetriesUnprocessedItems(t *testing.T) {
+	setMaxRetries(t, 3)
+
+	client := &unprocessedClient{throttled: 1}
+
+	_, err := batchWrite(context.Background(), client, "foo", putRequests(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the unprocessed item is sent again.
+	if len(client.calls) != 2 || client.calls[0] != 3 || client.calls[1] != 1 {
+		t.Errorf("expected batches of 3 and then 1, got %v", client.calls)
+	}
+}
+
+func TestBatchWriteGivesUpAfterMaxRetries(t *testing.T) {
+	setMaxRetries(t, 2)
+
+	client := &unprocessedClient{throttled: 100}
+
+	_, err := batchWrite(context.Background(), client, "foo", putRequests(3))
+	if err == nil || !strings.Contains(err.Error(), "still unprocessed after 2 retries") {
+		t.Fatalf("expected to give up after 2 retries, got %v", err)
+	}
+
+	if len(client.calls) != 3 {
+		t.Errorf("expected the first attempt and 2 retries, got %d calls", len(client.calls))
+	}
+}
+
+// recordingClient records every batch written to it.
+type recordingClient struct {
+	dynamoDBAPI
+
+	batches [][]types.WriteRequest
+}
+
+func (c *recordingClient) BatchWriteItem(_ context.Context, input *dynamodb.BatchWriteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	for _, requests := range input.RequestItems {
+		c.batches = append(c.batches, requests)
+	}
+
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func putRequest(id, value string) types.WriteRequest {
+	return types.WriteRequest{
+		PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+			"id":    &types.AttributeValueMemberS{Value: id},
+			"value": &types.AttributeValueMemberS{Value: value},
+		}},
+	}
+}
+
+func TestBatchWriterReplacesDuplicateKeys(t *testing.T) {
+	client := &recordingClient{}
+
+	var written int
+	writer := newBatchWriter(context.Background(), client, "foo", tableMetadata{PrimaryKey: "id"}, 1, func(n int, _ float64) {
+		written += n
+	})
+
+	for _, request := range []types.WriteRequest{
+		putRequest("1", "a"),
+		putRequest("2", "b"),
+		putRequest("1", "c"),
+		{DeleteRequest: &types.DeleteRequest{Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: "2"},
+		}}},
+	} {
+		err := writer.add(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := writer.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.batches) != 1 || len(client.batches[0]) != 2 {
+		t.Fatalf("expected a single batch of 2 requests, got %v", client.batches)
+	}
+
+	// The last request for each key is the one written, in the place of
+	// the first.
+	first, second := client.batches[0][0], client.batches[0][1]
+	if first.PutRequest == nil || first.PutRequest.Item["value"].(*types.AttributeValueMemberS).Value != "c" {
+		t.Errorf("expected the second put of 1 to be written, got %#v", first)
+	}
+
+	if second.DeleteRequest == nil {
+		t.Errorf("expected the delete of 2 to be written, got %#v", second)
+	}
+
+	if written != 4 {
+		t.Errorf("expected every request to be reported as written, got %d", written)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// checkpoint records how far through the table each scan segment has got, so
+// that an interrupted export can carry on from where it stopped.
+type checkpoint struct {
+	path string
+
+	TotalSegments int
+	Segments      map[int]*segmentCheckpoint
+}
+
+type segmentCheckpoint struct {
+	LastEvaluatedKey map[string]typedValue `json:",omitempty"`
+	Done             bool
+}
+
+// loadCheckpoint reads the checkpoint at path, or starts a new one if the
+// file does not exist yet.
+func loadCheckpoint(path string, segments int) (*checkpoint, error) {
+	cp := &checkpoint{
+		path:          path,
+		TotalSegments: segments,
+		Segments:      map[int]*segmentCheckpoint{},
+	}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, cp)
+	if err != nil {
+		return nil, err
+	}
+
+	if cp.TotalSegments != segments {
+		return nil, fmt.Errorf("checkpoint %s was taken with %d segments, not %d", path, cp.TotalSegments, segments)
+	}
+
+	return cp, nil
+}
+
+// resuming reports whether the checkpoint contains progress from an earlier
+// run.
+func (cp *checkpoint) resuming() bool {
+	return len(cp.Segments) > 0
+}
+
+// startKey returns the key the segment should be scanned from, and whether
+// the segment was already finished.
+func (cp *checkpoint) startKey(segment int) (map[string]types.AttributeValue, bool, error) {
+	s, ok := cp.Segments[segment]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if s.Done {
+		return nil, true, nil
+	}
+
+	key, err := attributeValues(s.LastEvaluatedKey)
+	return key, false, err
+}
+
+// update records the last key scanned by the segment and saves the
+// checkpoint. A nil key means the segment has been fully scanned.
+func (cp *checkpoint) update(segment int, key map[string]types.AttributeValue) error {
+	cp.Segments[segment] = &segmentCheckpoint{
+		LastEvaluatedKey: newTypedItem(key),
+		Done:             key == nil,
+	}
+
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so that the checkpoint is never left
+	// half written.
+	tmp := cp.path + ".tmp"
+
+	err = os.WriteFile(tmp, raw, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, cp.path)
+}
+
+// remove deletes the checkpoint once the export has completed.
+func (cp *checkpoint) remove() error {
+	return os.Remove(cp.path)
+}
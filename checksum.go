@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// manifestPath is where the manifest of an export written to path is kept.
+func manifestPath(path string) string {
+	return path + ".sha256"
+}
+
+// partNumber matches the number partPath adds to each part of a split export.
+var partNumber = regexp.MustCompile(`\.\d{3}(\.[^/]*)?$`)
+
+// writeManifest writes the checksum of each file of the export to a manifest
+// next to it, followed by the number of items they hold. It is in the format
+// of sha256sum, which skips the item count as a comment, so the files can be
+// checked with sha256sum -c too.
+func writeManifest(path string, files []string) error {
+	var manifest strings.Builder
+	var items int64
+
+	for _, file := range files {
+		sum, count, err := checksumExport(file)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, filepath.Base(file))
+		items += count
+	}
+
+	fmt.Fprintf(&manifest, "# %d items\n", items)
+
+	err := os.WriteFile(manifestPath(path), []byte(manifest.String()), 0o644)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("wrote the checksum of %d items to %s", items, manifestPath(path))
+	return nil
+}
+
+// checksumExport reads back an exported file, returning its checksum and the
+// number of items in it.
+func checksumExport(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	r := bufio.NewReader(io.TeeReader(file, hash))
+
+	input, err := decompress(r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	reader, err := newItemReader(input, format)
+	if err != nil {
+		return "", 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var count int64
+	for {
+		_, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: %w", path, err)
+		}
+
+		count++
+	}
+
+	// Anything after the last item still needs to be included.
+	_, err = io.Copy(io.Discard, r)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), count, nil
+}
+
+// verifyChecksums compares each file to be imported with the manifest
+// written alongside it, so that truncated or corrupted files are caught
+// before anything is written. Files without a manifest are only an error if
+// --verify-checksum is set.
+func verifyChecksums(paths []string) error {
+	for _, path := range paths {
+		if path == "-" || strings.HasPrefix(path, "s3://") {
+			if verifyChecksum {
+				return fmt.Errorf("--verify-checksum can only check local files, not %s", path)
+			}
+
+			continue
+		}
+
+		// The parts of a split export share the manifest of the whole.
+		manifest := manifestPath(path)
+		if _, err := os.Stat(manifest); errors.Is(err, os.ErrNotExist) {
+			manifest = manifestPath(partNumber.ReplaceAllString(path, "$1"))
+		}
+
+		expected, err := manifestChecksum(manifest, filepath.Base(path))
+		if errors.Is(err, os.ErrNotExist) {
+			if !verifyChecksum {
+				continue
+			}
+
+			return fmt.Errorf("there is no checksum manifest for %s", path)
+		}
+		if err != nil {
+			return err
+		}
+
+		sum, err := checksumFile(path)
+		if err != nil {
+			return err
+		}
+
+		if sum != expected {
+			return fmt.Errorf("the checksum of %s does not match %s, so it may be truncated or corrupted", path, manifest)
+		}
+
+		log.Printf("verified the checksum of %s", path)
+	}
+
+	return nil
+}
+
+// manifestChecksum finds the checksum of the named file in the manifest.
+func manifestChecksum(manifest, name string) (string, error) {
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		sum, file, ok := strings.Cut(line, "  ")
+		if ok && !strings.HasPrefix(line, "#") && file == name {
+			return sum, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s is not listed in %s", name, manifest)
+}
+
+func checksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v2"
+)
+
+// applyConfigFile sets flags from a YAML or JSON file, keyed by the names of
+// the flags without their dashes. Repeatable flags such as rename can be
+// given a list. Flags given on the command line take precedence, so those in
+// the file are only defaults.
+func applyConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// JSON is a subset of YAML, so both are read the same way.
+	var settings map[string]any
+	err = yaml.Unmarshal(data, &settings)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if flag.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("%s: unknown setting %s", path, name)
+		}
+
+		if explicit[name] {
+			continue
+		}
+
+		values, ok := settings[name].([]any)
+		if !ok {
+			values = []any{settings[name]}
+		}
+
+		for _, value := range values {
+			err := flag.Set(name, fmt.Sprint(value))
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", path, name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// convert reads a local file, or STDIN if path is -, in the from format and
+// writes it to w in the to format, without connecting to DynamoDB.
+func convert(path, from, to string, w *output) error {
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		file, err = os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+	}
+
+	input, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+
+	reader, err := newItemReader(input, from)
+	if err != nil {
+		return err
+	}
+
+	// Only csv needs every item before it can be written, to find all of
+	// the columns.
+	var items []map[string]any
+	var stream *jsonStream
+	var count int
+
+	encoder := json.NewEncoder(w)
+
+	switch to {
+	case formatJSONL:
+		err = writeJSONLHeader(encoder, reader.metadata())
+	case formatJSON:
+		stream, err = newJSONStream(w, reader.metadata(), pretty)
+	}
+	if err != nil {
+		return err
+	}
+
+	for {
+		item, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch to {
+		case formatJSON:
+			err = stream.write(item)
+		case formatJSONL:
+			err = encoder.Encode(item)
+		case formatDynamoDBJSON:
+			err = encodeTypedItem(encoder, item)
+		case formatCSV:
+			items = append(items, item)
+		}
+		if err != nil {
+			return fmt.Errorf("item %d: %w", count, err)
+		}
+
+		count++
+	}
+
+	switch to {
+	case formatJSON:
+		err = stream.close()
+	case formatCSV:
+		err = writeCSV(w, items)
+	}
+	if err != nil {
+		return err
+	}
+
+	successf("converted %d items from %s to %s", count, from, to)
+	return nil
+}
+
+// encodeTypedItem writes the item in the same shape as each line of a
+// DynamoDB export to S3, which is {"Item": {...}}.
+func encodeTypedItem(encoder *json.Encoder, item map[string]any) error {
+	marshaled, err := marshalPlainItem(item)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Encode(struct {
+		Item map[string]typedValue
+	}{
+		Item: newTypedItem(marshaled),
+	})
+}
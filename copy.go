@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// copyTable scans the table and writes every item straight into the target
+// table, which may be in another region or account, so that nothing is
+// written to disk in between. Attributes matching --exclude-attr are left
+// out, as they are from exports.
+func copyTable(ctx context.Context, source, destination dynamoDBAPI, name, target string) error {
+	table, err := describeTable(ctx, source, name)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := describeSchema(ctx, source, table)
+	if err != nil {
+		return err
+	}
+
+	// The ARN includes the region and account, so this also catches a
+	// --target-region or --target-profile which leads back to the same table.
+	existing, err := describeTable(ctx, destination, target)
+	if err == nil && aws.ToString(existing.TableArn) == aws.ToString(table.TableArn) {
+		return fmt.Errorf("%s and %s are the same table, so there is nothing to copy", name, target)
+	}
+
+	title := fmt.Sprintf("This will copy every item from %s into %s! Do you want to continue?", name, target)
+	if truncateTable {
+		title = fmt.Sprintf("This will DELETE ALL EXISTING DATA in %s, and then copy every item from %s into it! Do you want to continue?", target, name)
+	}
+
+	ok, err := confirm(title)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	if createMissingTable {
+		err = createTable(ctx, destination, target, metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	if truncateTable {
+		err = truncate(ctx, destination, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	input, err := newScanInput(name, metadata)
+	if err != nil {
+		return err
+	}
+
+	opts, err := exportScanOptions(ctx, source, metadata)
+	if err != nil {
+		return err
+	}
+
+	limiter := newWriteLimiter()
+	itemLimiter := newItemLimiter()
+	bar := newProgressBar(aws.ToInt64(table.ItemCount))
+
+	var written int
+	var capacity float64
+
+	defer func() {
+		if ctx.Err() != nil {
+			bar.done()
+			warnf("stopped after copying %d items to %s", written, target)
+		}
+	}()
+
+	writer := newBatchWriter(ctx, destination, target, metadata, concurrency, func(n int, units float64) {
+		written += n
+		capacity += units
+		bar.add(n)
+	})
+	defer writer.close()
+
+	stats, err := scanPages(ctx, source, input, opts, func(page []map[string]types.AttributeValue) error {
+		for _, item := range page {
+			maps.DeleteFunc(item, func(name string, _ types.AttributeValue) bool {
+				return excluded(name)
+			})
+
+			err := waitForCapacity(ctx, limiter, writeUnits(itemSize(item)))
+			if err != nil {
+				return err
+			}
+
+			err = waitForCapacity(ctx, itemLimiter, 1)
+			if err != nil {
+				return err
+			}
+
+			err = writer.add(types.WriteRequest{
+				PutRequest: &types.PutRequest{Item: item},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writer.close()
+	if err != nil {
+		return err
+	}
+
+	bar.done()
+
+	successf("copied %d items from %s to %s, consuming %.1f read and %.1f write capacity units", written, name, target, stats.capacity, capacity)
+	bar.logThroughput(written, capacity, "write")
+	return nil
+}
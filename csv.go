@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const formatCSV = "csv"
+
+// exportCSV writes one row per item, with a column for every top-level
+// attribute found in any item. Since the columns are only known once the
+// whole table has been scanned, every item is held in memory.
+func exportCSV(ctx context.Context, client dynamoDBAPI, input *dynamodb.ScanInput, opts scanOptions, w io.Writer, bar *progressBar) (scanStats, error) {
+	var items []map[string]any
+	stats, err := scanPages(ctx, client, input, opts, func(page []map[string]types.AttributeValue) error {
+		items = append(items, plainItems(page)...)
+		bar.add(len(page))
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, writeCSV(w, items)
+}
+
+// writeCSV writes the items with a column for every top-level attribute.
+func writeCSV(w io.Writer, items []map[string]any) error {
+	seen := map[string]bool{}
+	var columns []string
+	for _, item := range items {
+		for name := range item {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+		}
+	}
+
+	slices.Sort(columns)
+
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(columns)
+	if err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	for _, item := range items {
+		for i, name := range columns {
+			value, ok := item[name]
+			if !ok {
+				row[i] = ""
+				continue
+			}
+
+			row[i], err = csvCell(value)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		err = writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell formats strings, numbers and booleans as they are, and anything
+// else as embedded JSON. Strings which are themselves valid JSON, such as
+// "12345" or "true", are quoted so that they are not read back as numbers or
+// booleans, and so are empty strings, which would otherwise be read back as
+// a missing attribute.
+func csvCell(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		if v != "" && !json.Valid([]byte(v)) {
+			return v, nil
+		}
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(raw), nil
+}
+
+// csvReader reads the csv format. The first row names the attribute in each
+// column, and empty cells are treated as missing attributes. Cells which
+// are valid JSON are decoded as such, so that numbers, booleans and nested
+// values round trip, and anything else is a string.
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csvReader{
+		reader: csv.NewReader(r),
+	}
+
+	columns, err := reader.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	reader.columns = columns
+
+	return &reader, nil
+}
+
+func (r *csvReader) metadata() tableMetadata {
+	return tableMetadata{}
+}
+
+func (r *csvReader) total() int64 {
+	return 0
+}
+
+func (r *csvReader) next() (map[string]any, error) {
+	row, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	item := map[string]any{}
+	for i, cell := range row {
+		if cell == "" {
+			continue
+		}
+
+		item[r.columns[i]] = csvValue(cell)
+	}
+
+	return item, nil
+}
+
+func csvValue(cell string) any {
+	if !json.Valid([]byte(cell)) {
+		return cell
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(cell))
+	decoder.UseNumber()
+
+	var value any
+	err := decoder.Decode(&value)
+	if err != nil {
+		return cell
+	}
+
+	return value
+}
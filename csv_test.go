@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	item := map[string]any{
+		"zip":     "01234",
+		"count":   json.Number("12345"),
+		"numeric": "12345",
+		"flag":    true,
+		"boolean": "true",
+		"null":    "null",
+		"quoted":  `"quoted"`,
+		"list":    []any{json.Number("1"), "a"},
+		"text":    "hello, world",
+		"empty":   "",
+	}
+
+	var buf bytes.Buffer
+	err := writeCSV(&buf, []map[string]any{item})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := newCSVReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := reader.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, item) {
+		t.Errorf("expected %#v, got %#v", item, got)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const formatDynamoDBJSON = "dynamodb-json"
+
+// typedValue is the JSON representation of an attribute value which keeps
+// its DynamoDB type, in the same shape used by the AWS CLI and the DynamoDB
+// API itself, for example {"N": "42"}. Binary values are base64 encoded.
+type typedValue struct {
+	S    *string                `json:",omitempty"`
+	N    *string                `json:",omitempty"`
+	B    *[]byte                `json:",omitempty"`
+	BOOL *bool                  `json:",omitempty"`
+	NULL *bool                  `json:",omitempty"`
+	SS   []string               `json:",omitempty"`
+	NS   []string               `json:",omitempty"`
+	BS   [][]byte               `json:",omitempty"`
+	L    *[]typedValue          `json:",omitempty"`
+	M    *map[string]typedValue `json:",omitempty"`
+}
+
+func newTypedItem(item map[string]types.AttributeValue) map[string]typedValue {
+	typed := make(map[string]typedValue, len(item))
+	for name, value := range item {
+		typed[name] = newTypedValue(value)
+	}
+
+	return typed
+}
+
+func newTypedValue(value types.AttributeValue) typedValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return typedValue{S: &v.Value}
+	case *types.AttributeValueMemberN:
+		return typedValue{N: &v.Value}
+	case *types.AttributeValueMemberB:
+		return typedValue{B: &v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return typedValue{BOOL: &v.Value}
+	case *types.AttributeValueMemberNULL:
+		return typedValue{NULL: &v.Value}
+	case *types.AttributeValueMemberSS:
+		return typedValue{SS: v.Value}
+	case *types.AttributeValueMemberNS:
+		return typedValue{NS: v.Value}
+	case *types.AttributeValueMemberBS:
+		return typedValue{BS: v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]typedValue, len(v.Value))
+		for i, element := range v.Value {
+			list[i] = newTypedValue(element)
+		}
+		return typedValue{L: &list}
+	case *types.AttributeValueMemberM:
+		m := newTypedItem(v.Value)
+		return typedValue{M: &m}
+	}
+
+	return typedValue{}
+}
+
+func attributeValues(typed map[string]typedValue) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(typed))
+	for name, value := range typed {
+		av, err := value.attributeValue()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		item[name] = av
+	}
+
+	return item, nil
+}
+
+func (v typedValue) attributeValue() (types.AttributeValue, error) {
+	switch {
+	case v.S != nil:
+		return &types.AttributeValueMemberS{Value: *v.S}, nil
+	case v.N != nil:
+		return &types.AttributeValueMemberN{Value: *v.N}, nil
+	case v.B != nil:
+		return &types.AttributeValueMemberB{Value: *v.B}, nil
+	case v.BOOL != nil:
+		return &types.AttributeValueMemberBOOL{Value: *v.BOOL}, nil
+	case v.NULL != nil:
+		return &types.AttributeValueMemberNULL{Value: *v.NULL}, nil
+	case v.SS != nil:
+		return &types.AttributeValueMemberSS{Value: v.SS}, nil
+	case v.NS != nil:
+		return &types.AttributeValueMemberNS{Value: v.NS}, nil
+	case v.BS != nil:
+		return &types.AttributeValueMemberBS{Value: v.BS}, nil
+	case v.L != nil:
+		list := make([]types.AttributeValue, len(*v.L))
+		for i, element := range *v.L {
+			av, err := element.attributeValue()
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+
+			list[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case v.M != nil:
+		m, err := attributeValues(*v.M)
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	}
+
+	return nil, fmt.Errorf("attribute value has no type")
+}
+
+// dynamoDBJSONReader reads items in the typed DynamoDB JSON format, as
+// written by the AWS CLI and by DynamoDB's own exports to S3. It accepts any
+// number of objects, each holding either a single item under "Item", as in
+// an S3 export, or a list of them under "Items", as in the output of
+// aws dynamodb scan.
+type dynamoDBJSONReader struct {
+	decoder *json.Decoder
+	items   []map[string]typedValue
+}
+
+func newDynamoDBJSONReader(r io.Reader) *dynamoDBJSONReader {
+	return &dynamoDBJSONReader{
+		decoder: json.NewDecoder(r),
+	}
+}
+
+func (r *dynamoDBJSONReader) metadata() tableMetadata {
+	return tableMetadata{}
+}
+
+func (r *dynamoDBJSONReader) total() int64 {
+	return 0
+}
+
+func (r *dynamoDBJSONReader) next() (map[string]any, error) {
+	for len(r.items) == 0 {
+		var object struct {
+			Item  map[string]typedValue
+			Items []map[string]typedValue
+		}
+
+		err := r.decoder.Decode(&object)
+		if err != nil {
+			return nil, err
+		}
+
+		if object.Item != nil {
+			r.items = append(r.items, object.Item)
+		}
+
+		r.items = append(r.items, object.Items...)
+	}
+
+	typed := r.items[0]
+	r.items = r.items[1:]
+
+	item, err := attributeValues(typed)
+	if err != nil {
+		return nil, err
+	}
+
+	return plainItem(item), nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+)
+
+// The occurrences of a duplicated item which --dedup can keep.
+const (
+	keepFirst = "first"
+	keepLast  = "last"
+)
+
+// dedupReader drops items from the underlying reader which have the same key
+// as another item, keeping either the first or the last of them. When
+// keeping the last, every item has to be read upfront, so the whole file is
+// held in memory. If strict, a duplicate is an error instead.
+type dedupReader struct {
+	itemReader
+
+	schema tableMetadata
+	keep   string
+	strict bool
+
+	seen       map[string]bool
+	items      []map[string]any
+	loaded     bool
+	duplicates int
+}
+
+func newDedupReader(reader itemReader, schema tableMetadata, keep string, strict bool) *dedupReader {
+	return &dedupReader{
+		itemReader: reader,
+		schema:     schema,
+		keep:       keep,
+		strict:     strict,
+		seen:       map[string]bool{},
+	}
+}
+
+func (r *dedupReader) next() (map[string]any, error) {
+	if r.keep == keepLast {
+		return r.nextLast()
+	}
+
+	for {
+		item, err := r.itemReader.next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, duplicate, err := r.key(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if !duplicate {
+			return item, nil
+		}
+	}
+}
+
+func (r *dedupReader) nextLast() (map[string]any, error) {
+	if !r.loaded {
+		positions := map[string]int{}
+		for {
+			item, err := r.itemReader.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			key, duplicate, err := r.key(item)
+			if err != nil {
+				return nil, err
+			}
+
+			if duplicate {
+				r.items[positions[key]] = item
+				continue
+			}
+
+			positions[key] = len(r.items)
+			r.items = append(r.items, item)
+		}
+
+		r.loaded = true
+	}
+
+	if len(r.items) == 0 {
+		return nil, io.EOF
+	}
+
+	item := r.items[0]
+	r.items = r.items[1:]
+
+	return item, nil
+}
+
+// key returns the key of the item, and whether an item with the same key
+// has been seen before. The key is taken after --rename, --transform and
+// the other changes made before each item is written, which are applied to
+// a copy so that the import can apply them again and report any errors.
+// Items without a key are never duplicates, and are left for the import to
+// report too.
+func (r *dedupReader) key(item map[string]any) (string, bool, error) {
+	transformed := maps.Clone(item)
+
+	err := transformItem(transformed)
+	if err != nil {
+		return "", false, nil
+	}
+
+	attributes, err := itemKey(transformed, r.schema)
+	if err != nil {
+		return "", false, nil
+	}
+
+	key := typedJSON(attributes)
+	if !r.seen[key] {
+		r.seen[key] = true
+		return key, false, nil
+	}
+
+	if r.strict {
+		return "", false, fmt.Errorf("more than one item has the key %s", key)
+	}
+
+	r.duplicates++
+	return key, true, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+// sliceReader reads items from a slice.
+type sliceReader struct {
+	items []map[string]any
+}
+
+func (r *sliceReader) metadata() tableMetadata {
+	return tableMetadata{}
+}
+
+func (r *sliceReader) total() int64 {
+	return int64(len(r.items))
+}
+
+func (r *sliceReader) next() (map[string]any, error) {
+	if len(r.items) == 0 {
+		return nil, io.EOF
+	}
+
+	item := r.items[0]
+	r.items = r.items[1:]
+
+	return item, nil
+}
+
+func TestDedupAfterRename(t *testing.T) {
+	previous := renames
+	renames = []rename{{from: "oldKey", to: "id"}}
+	t.Cleanup(func() { renames = previous })
+
+	reader := newDedupReader(&sliceReader{items: []map[string]any{
+		{"oldKey": "1", "n": "a"},
+		{"oldKey": "2", "n": "b"},
+		{"oldKey": "1", "n": "c"},
+	}}, tableMetadata{PrimaryKey: "id"}, keepFirst, false)
+
+	var kept []string
+	for {
+		item, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The item itself is left for the import to transform.
+		if _, ok := item["oldKey"]; !ok {
+			t.Errorf("expected the item to be left as it was read, got %v", item)
+		}
+
+		kept = append(kept, item["n"].(string))
+	}
+
+	if len(kept) != 2 || kept[0] != "a" || kept[1] != "b" || reader.duplicates != 1 {
+		t.Errorf("expected a and b to be kept and 1 duplicate, got %v and %d", kept, reader.duplicates)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// deleteFromFile deletes every item in the file from the table, such as to
+// roll back an import. Only the key attributes of each item are used, so
+// the file can be a full export or one of just the keys.
+func deleteFromFile(ctx context.Context, client dynamoDBAPI, cfg aws.Config, path string) error {
+	reader, err := openItemReader(ctx, cfg, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	target := importTable()
+
+	description, err := describeTable(ctx, client, target)
+	if err != nil {
+		return err
+	}
+
+	metadata := newTableMetadata(description)
+
+	title := fmt.Sprintf("This will DELETE every item listed in %s from %s! Do you want to continue?", path, target)
+	if dryRun {
+		title = fmt.Sprintf("DRY RUN: this would delete every item listed in %s from %s, but nothing will be deleted. Do you want to continue?", path, target)
+	}
+
+	ok, err := confirm(title)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	if dryRun {
+		return validateKeys(reader, metadata, target)
+	}
+
+	bar := newProgressBar(reader.total())
+
+	var deleted int
+	defer func() {
+		if ctx.Err() != nil {
+			bar.done()
+			warnf("stopped after deleting %d items from %s", deleted, target)
+		}
+	}()
+
+	writer := newBatchWriter(ctx, client, target, metadata, concurrency, func(n int, _ float64) {
+		deleted += n
+		bar.add(n)
+	})
+	defer writer.close()
+
+	for index := 0; ; index++ {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		item, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		key, err := itemKey(item, metadata)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", index, err)
+		}
+
+		err = writer.add(types.WriteRequest{
+			DeleteRequest: &types.DeleteRequest{Key: key},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.close()
+	if err != nil {
+		return err
+	}
+
+	bar.done()
+
+	successf("deleted %d keys from %s", deleted, target)
+	return nil
+}
+
+// validateKeys checks that every item in the file has the key attributes of
+// the table, without deleting anything.
+func validateKeys(reader itemReader, metadata tableMetadata, target string) error {
+	var count int
+	for {
+		item, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = itemKey(item, metadata)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", count, err)
+		}
+
+		count++
+	}
+
+	fmt.Fprintf(os.Stderr, "DRY RUN: %d keys would be deleted from %s\n", count, target)
+	return nil
+}
+
+// itemKey returns the key attributes of an item read from one of the plain
+// formats.
+func itemKey(item map[string]any, metadata tableMetadata) (map[string]types.AttributeValue, error) {
+	names := []string{metadata.PrimaryKey}
+	if metadata.RangeKey != "" {
+		names = append(names, metadata.RangeKey)
+	}
+
+	key := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		value, ok := item[name]
+		if !ok {
+			return nil, fmt.Errorf("missing key attribute %s", name)
+		}
+
+		av, err := marshalPlainValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		key[name] = av
+	}
+
+	return key, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dustin/go-humanize"
+)
+
+// describe prints a summary of the table, its keys, indexes, TTL and tags,
+// without reading any items.
+func describe(ctx context.Context, client dynamoDBAPI, name string, w io.Writer) error {
+	table, err := describeTable(ctx, client, name)
+	if err != nil {
+		return err
+	}
+
+	metadata, err := describeSchema(ctx, client, table)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	row := func(label, value string) {
+		fmt.Fprintf(tw, "%s:\t%s\n", label, value)
+	}
+
+	row("Table", metadata.TableName)
+	row("Status", string(table.TableStatus))
+	row("Created", aws.ToTime(table.CreationDateTime).Format("2006-01-02 15:04:05 MST"))
+
+	// DynamoDB only updates these every few hours.
+	row("Items", humanize.Comma(aws.ToInt64(table.ItemCount))+" (approximate)")
+	row("Size", humanize.Bytes(uint64(aws.ToInt64(table.TableSizeBytes)))+" (approximate)")
+
+	row("Billing", describeCapacity(metadata.BillingMode, metadata.ProvisionedThroughput))
+	row("Key", describeKeySchema(metadata.KeySchema, metadata.AttributeDefinitions))
+
+	ttl := "disabled"
+	if metadata.TimeToLiveAttribute != "" {
+		ttl = fmt.Sprintf("%s (%s)", metadata.TimeToLiveAttribute, metadata.TimeToLiveStatus)
+	}
+	row("TTL", ttl)
+
+	for _, index := range metadata.GlobalSecondaryIndexes {
+		row("Global index "+index.IndexName, describeIndex(index, metadata))
+	}
+
+	for _, index := range metadata.LocalSecondaryIndexes {
+		row("Local index "+index.IndexName, describeIndex(index, metadata))
+	}
+
+	tags := make([]string, 0, len(metadata.Tags))
+	for key := range metadata.Tags {
+		tags = append(tags, key)
+	}
+	slices.Sort(tags)
+
+	for _, key := range tags {
+		row("Tag "+key, metadata.Tags[key])
+	}
+
+	return tw.Flush()
+}
+
+// describeKeySchema lists the keys with their types, such as id (S), ts (N).
+func describeKeySchema(keySchema []types.KeySchemaElement, definitions []types.AttributeDefinition) string {
+	var keys []string
+	for _, key := range keySchema {
+		name := aws.ToString(key.AttributeName)
+
+		for _, definition := range definitions {
+			if aws.ToString(definition.AttributeName) == name {
+				name = fmt.Sprintf("%s (%s)", name, definition.AttributeType)
+			}
+		}
+
+		keys = append(keys, name)
+	}
+
+	return strings.Join(keys, ", ")
+}
+
+func describeIndex(index secondaryIndex, metadata tableMetadata) string {
+	description := describeKeySchema(index.KeySchema, metadata.AttributeDefinitions)
+
+	if index.Projection != nil {
+		projection := string(index.Projection.ProjectionType)
+		if len(index.Projection.NonKeyAttributes) > 0 {
+			projection += " " + strings.Join(index.Projection.NonKeyAttributes, ", ")
+		}
+
+		description += ", projecting " + projection
+	}
+
+	if index.ProvisionedThroughput != nil {
+		description += ", " + describeCapacity(types.BillingModeProvisioned, index.ProvisionedThroughput)
+	}
+
+	return description
+}
+
+func describeCapacity(mode types.BillingMode, throughput *types.ProvisionedThroughput) string {
+	if mode != types.BillingModeProvisioned || throughput == nil {
+		return string(mode)
+	}
+
+	return fmt.Sprintf("%s, %d read and %d write capacity units", mode, aws.ToInt64(throughput.ReadCapacityUnits), aws.ToInt64(throughput.WriteCapacityUnits))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// diffFile compares the items in the file with those in the table, matching
+// them by key, and reports which are only in one or the other, and which are
+// in both but differ. Every item in the file is held in memory.
+func diffFile(ctx context.Context, client dynamoDBAPI, cfg aws.Config, path string) error {
+	reader, err := openItemReader(ctx, cfg, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	description, err := describeTable(ctx, client, tableName)
+	if err != nil {
+		return err
+	}
+
+	metadata := newTableMetadata(description)
+
+	// Items are compared by their typed JSON, which encoding/json always
+	// writes with the attributes in the same order.
+	fileItems := map[string]string{}
+	for index := 0; ; index++ {
+		item, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		key, err := itemKey(item, metadata)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", index, err)
+		}
+
+		marshaled, err := marshalPlainItem(item)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", index, err)
+		}
+
+		fileItems[typedJSON(key)] = typedJSON(marshaled)
+	}
+
+	var onlyTable, changed []string
+	var same int
+
+	scan := &dynamodb.ScanInput{
+		TableName:      &tableName,
+		ConsistentRead: aws.Bool(consistentRead),
+	}
+
+	n, err := scanSegments(ctx, client, tableName)
+	if err != nil {
+		return err
+	}
+
+	_, err = scanPages(ctx, client, scan, scanOptions{segments: n}, func(page []map[string]types.AttributeValue) error {
+		for _, item := range page {
+			key := typedJSON(itemKeyAttributes(item, metadata))
+
+			fileItem, ok := fileItems[key]
+			if !ok {
+				onlyTable = append(onlyTable, key)
+				continue
+			}
+
+			delete(fileItems, key)
+
+			if fileItem != typedJSON(item) {
+				changed = append(changed, key)
+				continue
+			}
+
+			same++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	onlyFile := make([]string, 0, len(fileItems))
+	for key := range fileItems {
+		onlyFile = append(onlyFile, key)
+	}
+
+	if verbose {
+		for _, keys := range [][]string{onlyFile, onlyTable, changed} {
+			slices.Sort(keys)
+		}
+
+		for _, key := range onlyFile {
+			log.Printf("only in %s: %s", path, key)
+		}
+
+		for _, key := range onlyTable {
+			log.Printf("only in %s: %s", tableName, key)
+		}
+
+		for _, key := range changed {
+			log.Printf("differs: %s", key)
+		}
+	}
+
+	log.Printf("%d items only in %s, %d only in %s, %d differ, %d are the same", len(onlyFile), path, len(onlyTable), tableName, len(changed), same)
+	return nil
+}
+
+// itemKeyAttributes returns just the key attributes of an item.
+func itemKeyAttributes(item map[string]types.AttributeValue, metadata tableMetadata) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{
+		metadata.PrimaryKey: item[metadata.PrimaryKey],
+	}
+
+	if metadata.RangeKey != "" {
+		key[metadata.RangeKey] = item[metadata.RangeKey]
+	}
+
+	return key
+}
+
+// typedJSON returns the item in the typed DynamoDB JSON format. Typed values
+// only hold strings, bytes and booleans, so they can always be marshaled.
+func typedJSON(item map[string]types.AttributeValue) string {
+	raw, _ := json.Marshal(newTypedItem(item))
+	return string(raw)
+}
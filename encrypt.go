@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted exports start with a header identifying the scheme, which is
+// AES-256-GCM with a key derived from the passphrase by PBKDF2-HMAC-SHA256:
+//
+//	magic (8) | salt (16) | iterations (4) | nonce prefix (7)
+//
+// The data follows in chunks of encryptedChunkSize bytes, each sealed
+// separately so that neither side holds the whole export in memory. The
+// nonce of each chunk is the prefix, the chunk number and a flag marking the
+// last chunk, so that chunks cannot be reordered and a truncated file is
+// caught rather than silently importing fewer items.
+var encryptionMagic = []byte("DDBMENC\x01")
+
+const (
+	encryptedChunkSize = 64 * 1024
+
+	saltSize        = 16
+	noncePrefixSize = 7
+	keySize         = 32
+
+	// keyIterations is the number of PBKDF2 iterations recommended by
+	// OWASP for HMAC-SHA256. It is recorded in the header, so it can be
+	// raised without breaking older exports.
+	keyIterations = 600_000
+
+	// maxKeyIterations bounds the count read from the header, so that a
+	// corrupted or hostile file cannot keep the import busy deriving a key.
+	maxKeyIterations = 10_000_000
+)
+
+// readPassphrase reads the passphrase from --passphrase-file, or otherwise
+// from $DDBM_PASSPHRASE. It is never taken from the command line, where
+// other users could see it in ps or /proc.
+func readPassphrase() (string, error) {
+	passphrase := os.Getenv("DDBM_PASSPHRASE")
+
+	if passphraseFile != "" {
+		data, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return "", err
+		}
+
+		passphrase = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if passphrase == "" {
+		return "", errors.New("no passphrase was given, either in --passphrase-file or $DDBM_PASSPHRASE")
+	}
+
+	return passphrase, nil
+}
+
+// encryptWriter encrypts everything written to it with --encrypt. Close must
+// be called to write the last chunk, but does not close w.
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	chunk  uint32
+	buf    []byte
+}
+
+func newEncryptWriter(w io.Writer, passphrase string) (*encryptWriter, error) {
+	header := make([]byte, 0, len(encryptionMagic)+saltSize+4+noncePrefixSize)
+	header = append(header, encryptionMagic...)
+
+	random := make([]byte, saltSize+noncePrefixSize)
+	_, err := rand.Read(random)
+	if err != nil {
+		return nil, err
+	}
+
+	salt, prefix := random[:saltSize], random[saltSize:]
+
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, keyIterations)
+	header = append(header, prefix...)
+
+	aead, err := newAEAD(passphrase, salt, keyIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		prefix: prefix,
+		buf:    make([]byte, 0, encryptedChunkSize),
+	}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		// A full chunk is only sealed once there is more to write, since
+		// until then it may turn out to be the last one.
+		if len(e.buf) == encryptedChunkSize {
+			err := e.seal(false)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		m := min(len(p), encryptedChunkSize-len(e.buf))
+		e.buf = append(e.buf, p[:m]...)
+
+		p = p[m:]
+		n += m
+	}
+
+	return n, nil
+}
+
+// Close writes the last chunk, which may be empty.
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.chunk, last), e.buf, nil)
+
+	_, err := e.w.Write(sealed)
+	if err != nil {
+		return err
+	}
+
+	e.chunk++
+	e.buf = e.buf[:0]
+
+	return nil
+}
+
+// decryptReader reverses encryptWriter.
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	chunk  uint32
+	buf    []byte
+	done   bool
+}
+
+// newDecryptReader reads the header of an encrypted export, which
+// isEncrypted has already found at the start of r.
+func newDecryptReader(r *bufio.Reader) (*decryptReader, error) {
+	passphrase, err := readPassphrase()
+	if err != nil {
+		return nil, fmt.Errorf("the input is encrypted, so its passphrase is needed: %w", err)
+	}
+
+	header := make([]byte, len(encryptionMagic)+saltSize+4+noncePrefixSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return nil, fmt.Errorf("reading the encryption header: %w", err)
+	}
+
+	header = header[len(encryptionMagic):]
+	salt := header[:saltSize]
+	iterations := binary.BigEndian.Uint32(header[saltSize:])
+	prefix := header[saltSize+4:]
+
+	if iterations == 0 || iterations > maxKeyIterations {
+		return nil, fmt.Errorf("the encryption header asks for %d key iterations, so the file is corrupted", iterations)
+	}
+
+	aead, err := newAEAD(passphrase, salt, int(iterations))
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, prefix: prefix}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		err := d.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	sealed := make([]byte, encryptedChunkSize+d.aead.Overhead())
+
+	n, err := io.ReadFull(d.r, sealed)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		d.done = true
+	} else if err != nil {
+		return err
+	} else if _, err := d.r.Peek(1); err == io.EOF {
+		d.done = true
+	}
+
+	d.buf, err = d.aead.Open(sealed[:0], chunkNonce(d.prefix, d.chunk, d.done), sealed[:n], nil)
+	if err != nil {
+		if d.chunk == 0 {
+			return errors.New("cannot decrypt the input, either the passphrase is wrong or the file is corrupted")
+		}
+
+		return fmt.Errorf("cannot decrypt chunk %d of the input, so the file is truncated or corrupted", d.chunk)
+	}
+
+	d.chunk++
+	return nil
+}
+
+// isEncrypted reports whether r starts with the header of an encrypted
+// export.
+func isEncrypted(r *bufio.Reader) (bool, error) {
+	magic, err := r.Peek(len(encryptionMagic))
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	return bytes.Equal(magic, encryptionMagic), nil
+}
+
+func chunkNonce(prefix []byte, chunk uint32, last bool) []byte {
+	nonce := make([]byte, 0, noncePrefixSize+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, chunk)
+
+	if last {
+		return append(nonce, 1)
+	}
+
+	return append(nonce, 0)
+}
+
+func newAEAD(passphrase string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, iterations, keySize, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func encrypt(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	passphrase, err := readPassphrase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+
+	w, err := newEncryptWriter(&buf, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func decrypt(encrypted []byte) ([]byte, error) {
+	r := bufio.NewReader(bytes.NewReader(encrypted))
+
+	d, err := newDecryptReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(d)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	// A trailing newline, as most editors write, is not part of the
+	// passphrase.
+	path := filepath.Join(t.TempDir(), "passphrase")
+	err := os.WriteFile(path, []byte("correct horse\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := passphraseFile
+	passphraseFile = path
+	t.Cleanup(func() { passphraseFile = previous })
+
+	data := bytes.Repeat([]byte("0123456789"), encryptedChunkSize/5)
+	encrypted := encrypt(t, data)
+
+	passphraseFile = ""
+	t.Setenv("DDBM_PASSPHRASE", "correct horse")
+
+	decrypted, err := decrypt(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decrypted, data) {
+		t.Errorf("expected %d bytes to come back, got %d", len(data), len(decrypted))
+	}
+}
+
+func TestDecryptRejectsLargeIterations(t *testing.T) {
+	t.Setenv("DDBM_PASSPHRASE", "correct horse")
+
+	encrypted := encrypt(t, []byte("hello"))
+	binary.BigEndian.PutUint32(encrypted[len(encryptionMagic)+saltSize:], maxKeyIterations+1)
+
+	_, err := decrypt(encrypted)
+	if err == nil || !strings.Contains(err.Error(), "key iterations") {
+		t.Errorf("expected the iteration count to be rejected, got %v", err)
+	}
+}
+
+func TestDecryptRequiresEncryptedInput(t *testing.T) {
+	previous := decryptInput
+	decryptInput = true
+	t.Cleanup(func() { decryptInput = previous })
+
+	_, err := decompress(bufio.NewReader(strings.NewReader(`{"TableName": "foo", "Items": []}`)))
+	if err == nil || !strings.Contains(err.Error(), "not encrypted") {
+		t.Errorf("expected the plain input to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,577 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+)
+
+type exportFormat struct {
+	tableMetadata
+
+	Items []map[string]any
+}
+
+// exportTables exports each of the tables to its own file, named after the
+// table, in the directory given by --output or under the S3 prefix given by
+// --s3.
+func exportTables(ctx context.Context, client dynamoDBAPI, cfg aws.Config, names []string) error {
+	if s3Path == "" {
+		err := os.MkdirAll(outputPath, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+
+	var total int
+	for _, name := range names {
+		file := name + outputExtension()
+
+		var s3URL, path string
+		if s3Path != "" {
+			s3URL = strings.TrimSuffix(s3Path, "/") + "/" + file
+		} else {
+			path = filepath.Join(outputPath, file)
+		}
+
+		out, err := openOutput(ctx, cfg, s3URL, path)
+		if err != nil {
+			return err
+		}
+
+		count, err := export(ctx, client, cfg, name, out)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		err = out.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if checksum {
+			err = writeManifest(path, out.files())
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+
+		total += count
+	}
+
+	successf("exported %d items from %d tables", total, len(names))
+	return nil
+}
+
+// export writes the table to w, returning the number of items exported.
+func export(ctx context.Context, client dynamoDBAPI, cfg aws.Config, name string, w *output) (int, error) {
+	table, err := describeTable(ctx, client, name)
+	if err != nil {
+		return 0, err
+	}
+
+	metadata, err := describeSchema(ctx, client, table)
+	if err != nil {
+		return 0, err
+	}
+
+	metadata = withProvenance(ctx, cfg, metadata)
+
+	if schemaOnly {
+		return 0, exportSchema(w, metadata)
+	}
+
+	if indexName != "" {
+		metadata, err = metadata.withIndex(indexName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	for _, key := range []string{metadata.PrimaryKey, metadata.RangeKey} {
+		if key != "" && excluded(key) {
+			return 0, fmt.Errorf("--exclude-attr would leave out the key attribute %s, so the export could not be imported", key)
+		}
+	}
+
+	// DynamoDB only refreshes the item count every few hours, so this is
+	// an approximation. There is no count for a single partition.
+	total := int64(float64(aws.ToInt64(table.ItemCount)) * sampleRate)
+	if queryKey != "" {
+		total = 0
+	}
+
+	bar := newProgressBar(total)
+
+	input, err := newScanInput(name, metadata)
+	if err != nil {
+		return 0, err
+	}
+
+	opts, err := exportScanOptions(ctx, client, metadata)
+	if err != nil {
+		return 0, err
+	}
+
+	if consistentRead {
+		log.Print("using strongly consistent reads, which consume twice as much read capacity")
+	}
+
+	if sampleRate < 1 {
+		log.Printf("sampling %g%% of items with --seed %d", sampleRate*100, seed)
+	}
+
+	var stats scanStats
+	switch format {
+	case formatJSONL:
+		stats, err = exportJSONL(ctx, client, input, opts, w, metadata, bar)
+	case formatCSV:
+		stats, err = exportCSV(ctx, client, input, opts, w, bar)
+	default:
+		stats, err = exportJSON(ctx, client, input, opts, w, metadata, bar)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	bar.done()
+
+	successf("exported %d items from %s, %s in total, consuming %.1f read capacity units", stats.items(), name, humanize.Bytes(uint64(w.written)), stats.capacity)
+	bar.logThroughput(stats.items(), stats.capacity, "read")
+
+	if verbose && len(stats.segmentItems) > 1 {
+		for segment, count := range stats.segmentItems {
+			log.Printf("segment %d: %d items", segment, count)
+		}
+	}
+
+	return stats.items(), nil
+}
+
+// exportJSON writes the table in the json format, streaming the items as
+// they are scanned.
+func exportJSON(ctx context.Context, client dynamoDBAPI, input *dynamodb.ScanInput, opts scanOptions, w *output, metadata tableMetadata, bar *progressBar) (scanStats, error) {
+	stream, err := newJSONStream(w, metadata, pretty)
+	if err != nil {
+		return scanStats{}, err
+	}
+
+	stats, err := scanPages(ctx, client, input, opts, func(page []map[string]types.AttributeValue) error {
+		for _, item := range plainItems(page) {
+			err := stream.write(item)
+			if err != nil {
+				return err
+			}
+		}
+
+		bar.add(len(page))
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, stream.close()
+}
+
+// exportJSONL writes the table metadata followed by one item per line,
+// without ever holding more than a single page of items in memory.
+//
+// With a checkpoint, the output is flushed after every page so that the
+// checkpoint never gets ahead of what has been written. When resuming, the
+// metadata is not written again, since the output is expected to be
+// appended to the earlier one.
+func exportJSONL(ctx context.Context, client dynamoDBAPI, input *dynamodb.ScanInput, opts scanOptions, w *output, metadata tableMetadata, bar *progressBar) (scanStats, error) {
+	encoder := json.NewEncoder(w)
+
+	var cp *checkpoint
+	if checkpointPath != "" {
+		var err error
+		cp, err = loadCheckpoint(checkpointPath, opts.segments)
+		if err != nil {
+			return scanStats{}, err
+		}
+	}
+
+	if cp == nil || !cp.resuming() {
+		err := writeJSONLHeader(encoder, metadata)
+		if err != nil {
+			return scanStats{}, err
+		}
+	}
+
+	opts.checkpoint = cp
+
+	stats, err := scanPages(ctx, client, input, opts, func(page []map[string]types.AttributeValue) error {
+		for _, item := range plainItems(page) {
+			// Every part starts with the metadata, so that each one can be
+			// imported on its own, unless it is kept in --metadata-file or
+			// left out with --no-metadata.
+			if w.full() {
+				err := w.rotate()
+				if err != nil {
+					return err
+				}
+
+				if metadataFile == "" && !noMetadata {
+					err = encoder.Encode(metadata)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			err := encoder.Encode(item)
+			if err != nil {
+				return err
+			}
+		}
+
+		bar.add(len(page))
+
+		if cp != nil {
+			return w.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+
+	if w.part > 0 {
+		log.Printf("split the export into %d files", w.part)
+	}
+
+	if cp != nil {
+		return stats, cp.remove()
+	}
+
+	return stats, nil
+}
+
+// writeJSONLHeader writes the metadata as the first line of the jsonl format,
+// or to --metadata-file instead so that every line is an item, such as to
+// pipe the export into jq. With --no-metadata it is not written at all.
+func writeJSONLHeader(encoder *json.Encoder, metadata tableMetadata) error {
+	if noMetadata {
+		return nil
+	}
+
+	if metadataFile == "" {
+		return encoder.Encode(metadata)
+	}
+
+	raw, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(metadataFile, append(raw, '\n'), 0o644)
+}
+
+// exportSchema writes the metadata of the table with no items.
+func exportSchema(w *output, metadata tableMetadata) error {
+	if format == formatJSONL {
+		err := json.NewEncoder(w).Encode(metadata)
+		if err != nil {
+			return err
+		}
+	} else {
+		stream, err := newJSONStream(w, metadata, pretty)
+		if err != nil {
+			return err
+		}
+
+		err = stream.close()
+		if err != nil {
+			return err
+		}
+	}
+
+	successf("exported the schema of %s", metadata.TableName)
+	return nil
+}
+
+// exportScanOptions returns how the table being exported is scanned, or
+// queried if --query-key is set.
+func exportScanOptions(ctx context.Context, client dynamoDBAPI, metadata tableMetadata) (scanOptions, error) {
+	n, err := scanSegments(ctx, client, metadata.TableName)
+	if err != nil {
+		return scanOptions{}, err
+	}
+
+	opts := scanOptions{
+		segments:   n,
+		limit:      limit,
+		sampleRate: sampleRate,
+		seed:       seed,
+	}
+
+	if queryKey == "" {
+		return opts, nil
+	}
+
+	value, err := keyValue(metadata, metadata.PrimaryKey, queryKey)
+	if err != nil {
+		return scanOptions{}, fmt.Errorf("--query-key: %w", err)
+	}
+
+	opts.keyCondition = &keyCondition{
+		expression: "#queryKey = :queryKey",
+		names:      map[string]string{"#queryKey": metadata.PrimaryKey},
+		values:     map[string]types.AttributeValue{":queryKey": value},
+	}
+
+	if queryRange != "" {
+		if metadata.RangeKey == "" {
+			return scanOptions{}, fmt.Errorf("--query-range: %s has no sort key", metadata.TableName)
+		}
+
+		// #sk names the sort key whatever it is called, since it is the
+		// only attribute the condition can use.
+		names := expressionNames(queryRange)
+		if _, ok := names["#sk"]; ok {
+			names["#sk"] = metadata.RangeKey
+		}
+
+		opts.keyCondition.expression += " AND " + queryRange
+		opts.keyCondition.names = mergeNames(opts.keyCondition.names, names)
+	}
+
+	return opts, nil
+}
+
+// keyValue converts a key attribute value given on the command line to the
+// type of the attribute.
+func keyValue(metadata tableMetadata, name, value string) (types.AttributeValue, error) {
+	for _, definition := range metadata.AttributeDefinitions {
+		if aws.ToString(definition.AttributeName) != name {
+			continue
+		}
+
+		switch definition.AttributeType {
+		case types.ScalarAttributeTypeN:
+			return &types.AttributeValueMemberN{Value: value}, nil
+		case types.ScalarAttributeTypeB:
+			b, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, err
+			}
+			return &types.AttributeValueMemberB{Value: b}, nil
+		}
+
+		return &types.AttributeValueMemberS{Value: value}, nil
+	}
+
+	return nil, fmt.Errorf("%s is not a key attribute", name)
+}
+
+// newScanInput returns the scan used to read the table being exported.
+func newScanInput(name string, metadata tableMetadata) (*dynamodb.ScanInput, error) {
+	input := &dynamodb.ScanInput{
+		TableName:              &name,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	}
+
+	if consistentRead {
+		input.ConsistentRead = aws.Bool(true)
+	}
+
+	if indexName != "" {
+		input.IndexName = &indexName
+	}
+
+	if pageSize > 0 {
+		input.Limit = aws.Int32(int32(pageSize))
+	}
+
+	if filter != "" {
+		input.FilterExpression = &filter
+		input.ExpressionAttributeNames = expressionNames(filter)
+	}
+
+	if attributes != "" {
+		projection, names := projectionExpression(strings.Split(attributes, ","))
+		input.ProjectionExpression = &projection
+		input.ExpressionAttributeNames = mergeNames(input.ExpressionAttributeNames, names)
+	}
+
+	if keysOnly {
+		projection, names := keyProjection(metadata)
+		input.ProjectionExpression = &projection
+		input.ExpressionAttributeNames = mergeNames(input.ExpressionAttributeNames, names)
+	}
+
+	if filterValues != "" {
+		values, err := expressionValues(filterValues)
+		if err != nil {
+			return nil, fmt.Errorf("--filter-values: %w", err)
+		}
+
+		input.ExpressionAttributeValues = values
+	}
+
+	if modifiedSince != "" || modifiedUntil != "" {
+		err := addModifiedFilter(input)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return input, nil
+}
+
+// addModifiedFilter adds a condition to the filter for items whose
+// --timestamp-attr, in seconds since the epoch, is at or after
+// --modified-since and before --modified-until, so that consecutive windows
+// never export the same item twice.
+func addModifiedFilter(input *dynamodb.ScanInput) error {
+	var conditions []string
+	values := map[string]types.AttributeValue{}
+
+	for _, bound := range []struct {
+		flag, value, placeholder, operator string
+	}{
+		{"--modified-since", modifiedSince, ":ddbmModifiedSince", ">="},
+		{"--modified-until", modifiedUntil, ":ddbmModifiedUntil", "<"},
+	} {
+		if bound.value == "" {
+			continue
+		}
+
+		t, err := parseTimestamp(bound.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", bound.flag, err)
+		}
+
+		conditions = append(conditions, fmt.Sprintf("#ddbmTimestamp %s %s", bound.operator, bound.placeholder))
+		values[bound.placeholder] = &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+	}
+
+	condition := strings.Join(conditions, " AND ")
+	if input.FilterExpression != nil {
+		condition = fmt.Sprintf("(%s) AND %s", *input.FilterExpression, condition)
+	}
+
+	input.FilterExpression = &condition
+	input.ExpressionAttributeNames = mergeNames(input.ExpressionAttributeNames, map[string]string{"#ddbmTimestamp": timestampAttribute})
+
+	if input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = values
+	} else {
+		maps.Copy(input.ExpressionAttributeValues, values)
+	}
+
+	return nil
+}
+
+// parseTimestamp parses a time given in RFC 3339, such as
+// 2024-06-01T00:00:00Z, or as seconds since the epoch.
+func parseTimestamp(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC 3339 nor seconds since the epoch", value)
+	}
+
+	return t, nil
+}
+
+var namePlaceholder = regexp.MustCompile(`#([A-Za-z0-9_]+)`)
+
+// expressionNames maps every #name placeholder in the expression to the
+// attribute of the same name, so that reserved words can be used in an
+// expression by prefixing them with #.
+func expressionNames(expression string) map[string]string {
+	matches := namePlaceholder.FindAllStringSubmatch(expression, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	names := make(map[string]string, len(matches))
+	for _, match := range matches {
+		names[match[0]] = match[1]
+	}
+
+	return names
+}
+
+// projectionExpression returns a projection of the named top-level
+// attributes, using a placeholder for each name in case it is a reserved
+// word.
+func projectionExpression(attributes []string) (string, map[string]string) {
+	names := make(map[string]string, len(attributes))
+	placeholders := make([]string, 0, len(attributes))
+
+	for i, attribute := range attributes {
+		placeholder := fmt.Sprintf("#attr%d", i)
+		names[placeholder] = strings.TrimSpace(attribute)
+		placeholders = append(placeholders, placeholder)
+	}
+
+	return strings.Join(placeholders, ", "), names
+}
+
+func mergeNames(a, b map[string]string) map[string]string {
+	if a == nil {
+		return b
+	}
+
+	for placeholder, name := range b {
+		a[placeholder] = name
+	}
+
+	return a
+}
+
+// expressionValues parses a JSON object of expression attribute values, such
+// as {":status": "active"}. The leading colon may be left off the names.
+func expressionValues(raw string) (map[string]types.AttributeValue, error) {
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+
+	var values map[string]any
+	err := decoder.Decode(&values)
+	if err != nil {
+		return nil, err
+	}
+
+	marshaled, err := marshalPlainItem(values)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixed := make(map[string]types.AttributeValue, len(marshaled))
+	for name, value := range marshaled {
+		if !strings.HasPrefix(name, ":") {
+			name = ":" + name
+		}
+
+		prefixed[name] = value
+	}
+
+	return prefixed, nil
+}
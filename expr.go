@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+// errMissingAttribute is returned when an expression uses an attribute which
+// the item does not have, in which case the transform is skipped.
+var errMissingAttribute = errors.New("missing attribute")
+
+// transform sets a top-level attribute to the result of an expression, as
+// given by --transform.
+type transform struct {
+	name string
+	expr expression
+}
+
+// expression is a tiny language for computing attribute values from others,
+// with string and number literals, top-level attribute names, the operators
+// + - * / on numbers, parentheses, and the functions in transformFunctions.
+type expression interface {
+	eval(item map[string]any) (any, error)
+}
+
+type literal struct {
+	value any
+}
+
+type attribute struct {
+	name string
+}
+
+type arithmetic struct {
+	op          rune
+	left, right expression
+}
+
+type call struct {
+	name string
+	args []expression
+}
+
+// transformFunctions are the functions which can be called in a transform,
+// each taking the evaluated arguments.
+var transformFunctions = map[string]func(args []any) (any, error){
+	"concat": func(args []any) (any, error) {
+		var b strings.Builder
+		for _, arg := range args {
+			s, err := toString(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			b.WriteString(s)
+		}
+
+		return b.String(), nil
+	},
+	"upper":  stringFunction(strings.ToUpper),
+	"lower":  stringFunction(strings.ToLower),
+	"trim":   stringFunction(strings.TrimSpace),
+	"string": stringFunction(func(s string) string { return s }),
+	"number": func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("takes 1 argument, not %d", len(args))
+		}
+
+		r, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return fromNumber(r), nil
+	},
+}
+
+func stringFunction(f func(string) string) func(args []any) (any, error) {
+	return func(args []any) (any, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("takes 1 argument, not %d", len(args))
+		}
+
+		s, err := toString(args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		return f(s), nil
+	}
+}
+
+// parseTransform parses a --transform value of the form name = expression,
+// such as fullName = concat(first, " ", last).
+func parseTransform(value string) (transform, error) {
+	name, source, ok := strings.Cut(value, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return transform{}, fmt.Errorf("%q is not of the form name = expression", value)
+	}
+
+	p := &parser{}
+	p.scanner.Init(strings.NewReader(source))
+	p.scanner.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings
+	p.scanner.Error = func(s *scanner.Scanner, msg string) {
+		p.err = fmt.Errorf("%s at column %d", msg, s.Position.Column)
+	}
+	p.next()
+
+	expr := p.parseSum()
+	if p.err == nil && p.token != scanner.EOF {
+		p.fail("unexpected %s", p.scanner.TokenText())
+	}
+	if p.err != nil {
+		return transform{}, fmt.Errorf("%s: %w", name, p.err)
+	}
+
+	return transform{name: name, expr: expr}, nil
+}
+
+// parser is a recursive descent parser for expressions, which stops at the
+// first error.
+type parser struct {
+	scanner scanner.Scanner
+	token   rune
+	err     error
+}
+
+func (p *parser) next() {
+	p.token = p.scanner.Scan()
+}
+
+func (p *parser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format+" at column %d", append(args, p.scanner.Position.Column)...)
+	}
+}
+
+func (p *parser) parseSum() expression {
+	expr := p.parseProduct()
+	for p.err == nil && (p.token == '+' || p.token == '-') {
+		op := p.token
+		p.next()
+		expr = arithmetic{op: op, left: expr, right: p.parseProduct()}
+	}
+
+	return expr
+}
+
+func (p *parser) parseProduct() expression {
+	expr := p.parseOperand()
+	for p.err == nil && (p.token == '*' || p.token == '/') {
+		op := p.token
+		p.next()
+		expr = arithmetic{op: op, left: expr, right: p.parseOperand()}
+	}
+
+	return expr
+}
+
+func (p *parser) parseOperand() expression {
+	text := p.scanner.TokenText()
+
+	switch p.token {
+	case scanner.String:
+		p.next()
+		s, err := strconv.Unquote(text)
+		if err != nil {
+			p.fail("invalid string %s", text)
+		}
+
+		return literal{value: s}
+	case scanner.Int, scanner.Float:
+		p.next()
+		return literal{value: json.Number(text)}
+	case '-':
+		p.next()
+		return arithmetic{op: '-', left: literal{value: json.Number("0")}, right: p.parseOperand()}
+	case '(':
+		p.next()
+		expr := p.parseSum()
+		if p.token != ')' {
+			p.fail("expected )")
+		}
+		p.next()
+
+		return expr
+	case scanner.Ident:
+		p.next()
+		if p.token != '(' {
+			return attribute{name: text}
+		}
+
+		if _, ok := transformFunctions[text]; !ok {
+			p.fail("unknown function %s", text)
+		}
+		p.next()
+
+		c := call{name: text}
+		for p.err == nil && p.token != ')' {
+			c.args = append(c.args, p.parseSum())
+
+			if p.token == ',' {
+				p.next()
+			} else if p.token != ')' {
+				p.fail("expected , or )")
+			}
+		}
+		p.next()
+
+		return c
+	}
+
+	if p.token == scanner.EOF {
+		p.fail("unexpected end of expression")
+	} else {
+		p.fail("unexpected %s", text)
+	}
+
+	return nil
+}
+
+func (l literal) eval(map[string]any) (any, error) {
+	return l.value, nil
+}
+
+func (a attribute) eval(item map[string]any) (any, error) {
+	value, ok := item[a.name]
+	if !ok {
+		return nil, errMissingAttribute
+	}
+
+	return value, nil
+}
+
+func (a arithmetic) eval(item map[string]any) (any, error) {
+	var operands [2]*big.Rat
+	for i, expr := range []expression{a.left, a.right} {
+		value, err := expr.eval(item)
+		if err != nil {
+			return nil, err
+		}
+
+		operands[i], err = toNumber(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	x, y := operands[0], operands[1]
+	result := new(big.Rat)
+
+	switch a.op {
+	case '+':
+		result.Add(x, y)
+	case '-':
+		result.Sub(x, y)
+	case '*':
+		result.Mul(x, y)
+	case '/':
+		if y.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+
+		result.Quo(x, y)
+	}
+
+	return fromNumber(result), nil
+}
+
+func (c call) eval(item map[string]any) (any, error) {
+	args := make([]any, len(c.args))
+	for i, expr := range c.args {
+		var err error
+		args[i], err = expr.eval(item)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := transformFunctions[c.name](args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+
+	return value, nil
+}
+
+// toString converts a string, number or boolean to a string.
+func toString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return string(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+
+	return "", fmt.Errorf("cannot use %v as a string", value)
+}
+
+// toNumber converts a number, or a string holding one, to an exact rational
+// so that arithmetic keeps the precision of DynamoDB numbers.
+func toNumber(value any) (*big.Rat, error) {
+	var s string
+	switch v := value.(type) {
+	case json.Number:
+		s = string(v)
+	case string:
+		s = strings.TrimSpace(v)
+	default:
+		return nil, fmt.Errorf("cannot use %v as a number", value)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a number", s)
+	}
+
+	return r, nil
+}
+
+// fromNumber formats the result of arithmetic as a number attribute, with at
+// most the 38 significant digits DynamoDB supports.
+func fromNumber(r *big.Rat) json.Number {
+	if r.IsInt() {
+		return json.Number(r.Num().String())
+	}
+
+	f := new(big.Float).SetPrec(128).SetRat(r)
+	return json.Number(f.Text('g', 38))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// importFailures collects the items which could not be imported under
+// --continue-on-error. It is safe for concurrent use, since batches fail on
+// the writer's workers.
+type importFailures struct {
+	mu       sync.Mutex
+	schema   tableMetadata
+	failures []importFailure
+}
+
+type importFailure struct {
+	item map[string]any
+	err  error
+}
+
+func (f *importFailures) add(item map[string]any, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = append(f.failures, importFailure{item: item, err: err})
+}
+
+// count returns the number of items which could not be imported.
+func (f *importFailures) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.failures)
+}
+
+// report logs every failure, and writes the failed items to path if it is
+// set, in the jsonl format so that they can be imported again once fixed.
+// It returns an error if there were any failures.
+func (f *importFailures) report(path string, metadata tableMetadata) error {
+	if len(f.failures) == 0 {
+		return nil
+	}
+
+	for _, failure := range f.failures {
+		errorf("failed to import item%s: %s", describeKey(failure.item, f.schema), failure.err)
+	}
+
+	if path != "" {
+		err := f.write(path, metadata)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("wrote the items which failed to %s", path)
+	}
+
+	return fmt.Errorf("%d items could not be imported", len(f.failures))
+}
+
+func (f *importFailures) write(path string, metadata tableMetadata) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+
+	err = encoder.Encode(metadata)
+	if err != nil {
+		return err
+	}
+
+	for _, failure := range f.failures {
+		err = encoder.Encode(failure.item)
+		if err != nil {
+			return err
+		}
+	}
+
+	return file.Close()
+}
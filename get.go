@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// getItem prints the single item with the key given by --get and --get-sort,
+// read with GetItem rather than a scan. The key attributes and their types
+// are taken from the table.
+func getItem(ctx context.Context, client dynamoDBAPI, name string, w io.Writer) error {
+	table, err := describeTable(ctx, client, name)
+	if err != nil {
+		return err
+	}
+
+	metadata := newTableMetadata(table)
+
+	value, err := keyValue(metadata, metadata.PrimaryKey, getKey)
+	if err != nil {
+		return fmt.Errorf("--get: %w", err)
+	}
+
+	key := map[string]types.AttributeValue{metadata.PrimaryKey: value}
+	description := fmt.Sprintf("%s %s", metadata.PrimaryKey, getKey)
+
+	if metadata.RangeKey == "" && getSortKey != "" {
+		return fmt.Errorf("%s has no sort key, so --get-sort cannot be used", name)
+	}
+
+	if metadata.RangeKey != "" {
+		if getSortKey == "" {
+			return fmt.Errorf("%s has the sort key %s, so --get-sort is also needed", name, metadata.RangeKey)
+		}
+
+		key[metadata.RangeKey], err = keyValue(metadata, metadata.RangeKey, getSortKey)
+		if err != nil {
+			return fmt.Errorf("--get-sort: %w", err)
+		}
+
+		description += fmt.Sprintf(" and %s %s", metadata.RangeKey, getSortKey)
+	}
+
+	output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &name,
+		Key:            key,
+		ConsistentRead: aws.Bool(consistentRead),
+	})
+	if err != nil {
+		return err
+	}
+
+	if output.Item == nil {
+		return fmt.Errorf("%s has no item with %s", name, description)
+	}
+
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+
+	return encoder.Encode(plainItems([]map[string]types.AttributeValue{output.Item})[0])
+}
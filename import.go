@@ -0,0 +1,661 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dustin/go-humanize"
+)
+
+// The modes for importing items which already exist in the table.
+const (
+	modeOverwrite      = "overwrite"
+	modeSkipExisting   = "skip-existing"
+	modeFailOnConflict = "fail-on-conflict"
+)
+
+func importFromFile(ctx context.Context, client dynamoDBAPI, cfg aws.Config, path string) error {
+	paths, err := importPaths(path)
+	if err != nil {
+		return err
+	}
+
+	err = verifyChecksums(paths)
+	if err != nil {
+		return err
+	}
+
+	reader, err := openItemReader(ctx, cfg, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if provenance := reader.metadata().provenance(); provenance != "" {
+		log.Printf("%s was %s", path, provenance)
+	}
+
+	source := "data"
+	if total := reader.total(); total > 0 {
+		source = fmt.Sprintf("%s items", humanize.Comma(total))
+	}
+
+	if name := reader.metadata().TableName; name != "" {
+		source = fmt.Sprintf("%s exported from %s", source, name)
+	}
+
+	return importItems(ctx, client, reader, source)
+}
+
+// importItems writes every item from the reader to the table, once the user
+// has confirmed the import of source, which describes where the items came
+// from.
+func importItems(ctx context.Context, client dynamoDBAPI, reader itemReader, source string) error {
+	target := importTable()
+
+	title := fmt.Sprintf("This will import %s into %s! Do you want to continue?", source, target)
+	if truncateTable {
+		existing := "ALL EXISTING DATA"
+
+		// DynamoDB only updates the item count every few hours, but it is
+		// enough to show the scale of what is about to be deleted.
+		if description, err := describeTable(ctx, client, target); err == nil {
+			existing = fmt.Sprintf("ALL of the roughly %s existing items", humanize.Comma(aws.ToInt64(description.ItemCount)))
+		}
+
+		title = fmt.Sprintf("This will DELETE %s in %s, and then import %s into it! Do you want to continue?", existing, target, source)
+	}
+
+	if dryRun {
+		title = fmt.Sprintf("DRY RUN: this would import %s into %s, but no data will be written. Do you want to continue?", source, target)
+	}
+
+	ok, err := confirm(title)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return nil
+	}
+
+	if dryRun {
+		return validate(reader, target)
+	}
+
+	if createMissingTable {
+		err = createTable(ctx, client, target, reader.metadata())
+		if err != nil {
+			return err
+		}
+	}
+
+	if truncateTable {
+		err = truncate(ctx, client, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	description, err := describeTable(ctx, client, target)
+	if err != nil {
+		return err
+	}
+
+	schema := newTableMetadata(description)
+
+	var items itemReader = reader
+
+	var deduped *dedupReader
+	if dedup != "" || strictDuplicates {
+		deduped = newDedupReader(reader, schema, cmp.Or(dedup, keepFirst), strictDuplicates)
+		items = deduped
+	}
+
+	var ttlAttribute string
+	if skipExpired {
+		ttlAttribute, err = timeToLiveAttribute(ctx, client, target)
+		if err != nil {
+			return err
+		}
+
+		if ttlAttribute == "" {
+			warnf("%s has no TTL attribute, so no items will be skipped as expired", target)
+		}
+	}
+
+	now := time.Now().Unix()
+
+	limiter := newWriteLimiter()
+	itemLimiter := newItemLimiter()
+	bar := newProgressBar(reader.total())
+
+	var read, written, skipped, expired, oversized, invalid, resumed int
+	var capacity float64
+
+	// When interrupted or timed out, report how far the import got, so it
+	// is clear how much of the table has been written.
+	defer func() {
+		if ctx.Err() != nil {
+			bar.done()
+			warnf("stopped after writing %d items to %s", written, target)
+		}
+	}()
+
+	// The progress file is opened before the writer, so that its deferred
+	// close runs after the writer's and still records the batches which
+	// finish while the writer is closing.
+	var progress *importProgress
+	if progressFile != "" {
+		progress, err = openImportProgress(progressFile, schema)
+		if err != nil {
+			return err
+		}
+		defer progress.close()
+
+		if n := progress.resuming(); n > 0 {
+			log.Printf("resuming from %s, which lists %d items already imported", progressFile, n)
+		}
+	}
+
+	writer := newBatchWriter(ctx, client, target, schema, concurrency, func(n int, units float64) {
+		written += n
+		capacity += units
+		bar.add(n)
+	})
+	defer writer.close()
+
+	failures := &importFailures{schema: schema}
+
+	// fail records the item as failed under --continue-on-error, and
+	// otherwise returns the error to stop the import.
+	fail := func(item map[string]any, err error) error {
+		if !continueOnError {
+			return err
+		}
+
+		failures.add(item, err)
+		bar.add(1)
+		return nil
+	}
+
+	if progress != nil {
+		writer.onWritten = func(batch []types.WriteRequest) {
+			for _, request := range batch {
+				progress.record(request.PutRequest.Item)
+			}
+		}
+	}
+
+	if continueOnError {
+		writer.onError = func(batch []types.WriteRequest, err error) {
+			for _, request := range batch {
+				failures.add(plainItem(request.PutRequest.Item), err)
+			}
+
+			// The failed items are done with too, as those which fail
+			// before reaching the writer are.
+			bar.add(len(batch))
+		}
+	}
+
+	for {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		item, err := items.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		read++
+
+		err = transformItem(item)
+		if err != nil {
+			if err := fail(item, fmt.Errorf("item %d: %w", read, err)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if ttlAttribute != "" && isExpired(item[ttlAttribute], now) {
+			expired++
+			bar.add(1)
+			continue
+		}
+
+		// Check for the key first, since DynamoDB only reports which batch
+		// was invalid rather than which item.
+		key, err := itemKey(item, schema)
+		if err != nil {
+			err := fmt.Errorf("item %d: %w", read, err)
+			if !skipInvalid {
+				if err := fail(item, err); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			warnf("skipping %s", err)
+			invalid++
+			bar.add(1)
+			continue
+		}
+
+		if progress != nil && progress.done(key) {
+			resumed++
+			bar.add(1)
+			continue
+		}
+
+		mapdata, err := marshalPlainItem(item)
+		if err != nil {
+			if err := fail(item, err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		size := itemSize(mapdata)
+		if size > maxItemSize {
+			err := fmt.Errorf("item %d%s is %s, over the %s DynamoDB allows", read, describeKey(item, schema), humanize.IBytes(uint64(size)), humanize.IBytes(maxItemSize))
+			if !skipOversized {
+				if err := fail(item, err); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			warnf("skipping %s", err)
+			oversized++
+			bar.add(1)
+			continue
+		}
+
+		err = waitForCapacity(ctx, limiter, writeUnits(size))
+		if err != nil {
+			return err
+		}
+
+		err = waitForCapacity(ctx, itemLimiter, 1)
+		if err != nil {
+			return err
+		}
+
+		// Conditional writes are not supported by BatchWriteItem, so they
+		// have to be made one item at a time.
+		if importMode != modeOverwrite || conditionalOn != "" {
+			ok, units, err := putConditionally(ctx, client, target, schema.PrimaryKey, mapdata)
+			if err != nil {
+				if err := fail(item, err); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			capacity += units
+
+			if progress != nil && (ok || importMode != modeFailOnConflict) {
+				progress.record(mapdata)
+			}
+
+			if !ok && importMode == modeFailOnConflict {
+				return fmt.Errorf("item %d already exists in %s, with %s %v, stopped after writing %d items", read, target, schema.PrimaryKey, plainValue(mapdata[schema.PrimaryKey]), written)
+			}
+
+			if ok {
+				written++
+			} else {
+				skipped++
+			}
+
+			bar.add(1)
+			continue
+		}
+
+		err = writer.add(types.WriteRequest{
+			PutRequest: &types.PutRequest{Item: mapdata},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.close()
+	if err != nil {
+		return err
+	}
+
+	bar.done()
+
+	if conditionalOn != "" {
+		successf("wrote %d items to %s, skipped %d which were no newer than the existing item", written, target, skipped)
+	} else if importMode == modeSkipExisting {
+		successf("wrote %d items to %s, skipped %d which already existed", written, target, skipped)
+	} else {
+		successf("wrote %d items to %s", written, target)
+	}
+
+	if skipExpired {
+		warnf("skipped %d items which had already expired", expired)
+	}
+
+	if resumed > 0 {
+		log.Printf("skipped %d items imported by an earlier run", resumed)
+	}
+
+	if deduped != nil {
+		log.Printf("collapsed %d items with the same key as another", deduped.duplicates)
+	}
+
+	if invalid > 0 {
+		warnf("skipped %d items which were missing key attributes", invalid)
+	}
+
+	if oversized > 0 {
+		warnf("skipped %d items which were too large", oversized)
+	}
+
+	log.Printf("consumed %.1f write capacity units", capacity)
+	bar.logThroughput(written, capacity, "write")
+
+	// The table is verified before the failures are reported, since that
+	// stops the import, and the failed items are not expected to be in it.
+	if verifyImport {
+		err = verify(ctx, client, target, read-expired-oversized-invalid-failures.count())
+		if err != nil {
+			return err
+		}
+	}
+
+	err = failures.report(errorFile, reader.metadata())
+	if err != nil {
+		return err
+	}
+
+	// Once everything has been imported, the progress is no longer needed.
+	if progress != nil {
+		err = progress.close()
+		if err != nil {
+			return err
+		}
+
+		err = progress.remove()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// describeKey describes the key of an item for error messages, such as
+// " (id foo)", if the key attributes are known from the import file.
+func describeKey(item map[string]any, metadata tableMetadata) string {
+	if metadata.PrimaryKey == "" {
+		return ""
+	}
+
+	key := fmt.Sprintf("%s %v", metadata.PrimaryKey, item[metadata.PrimaryKey])
+	if metadata.RangeKey != "" {
+		key += fmt.Sprintf(", %s %v", metadata.RangeKey, item[metadata.RangeKey])
+	}
+
+	return " (" + key + ")"
+}
+
+// timeToLiveAttribute returns the TTL attribute of the table, or an empty
+// string if it has none.
+func timeToLiveAttribute(ctx context.Context, client dynamoDBAPI, table string) (string, error) {
+	description, err := describeTable(ctx, client, table)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := describeSchema(ctx, client, description)
+	if err != nil {
+		return "", err
+	}
+
+	return metadata.TimeToLiveAttribute, nil
+}
+
+// isExpired reports whether a TTL attribute value, in seconds since the
+// epoch, is before now. Like DynamoDB, values which are not numbers never
+// expire.
+func isExpired(value any, now int64) bool {
+	n, ok := value.(json.Number)
+	if !ok {
+		return false
+	}
+
+	expiry, err := n.Float64()
+	if err != nil {
+		return false
+	}
+
+	return expiry < float64(now)
+}
+
+// verify counts the items in the table, and warns if it does not match the
+// number of items in the import file.
+func verify(ctx context.Context, client dynamoDBAPI, table string, expected int) error {
+	n, err := scanSegments(ctx, client, table)
+	if err != nil {
+		return err
+	}
+
+	count, err := countItems(ctx, client, table, n)
+	if err != nil {
+		return err
+	}
+
+	if count != expected {
+		warnf("%s contains %d items, but the import file contains %d", table, count, expected)
+		return nil
+	}
+
+	successf("verified %s contains %d items", table, count)
+	return nil
+}
+
+// putConditionally writes the item only if no item with the same key already
+// exists or, with --conditional-on, if the existing item is older. It returns
+// false if the item was skipped, along with the write capacity units consumed.
+func putConditionally(ctx context.Context, client dynamoDBAPI, table, primaryKey string, item map[string]types.AttributeValue) (bool, float64, error) {
+	input := &dynamodb.PutItemInput{
+		TableName:                &table,
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#pk)"),
+		ExpressionAttributeNames: map[string]string{"#pk": primaryKey},
+		ReturnConsumedCapacity:   types.ReturnConsumedCapacityTotal,
+	}
+
+	// Items without the attribute can never be newer, so they are only
+	// written if there is no existing item.
+	if incoming, ok := item[conditionalOn]; ok && conditionalOn != "" {
+		input.ConditionExpression = aws.String("attribute_not_exists(#pk) OR #version < :incoming")
+		input.ExpressionAttributeNames["#version"] = conditionalOn
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{":incoming": incoming}
+	}
+
+	output, err := client.PutItem(ctx, input)
+
+	// A failed condition still consumes capacity, but the SDK does not
+	// return it with the error.
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, 0, nil
+	}
+
+	if err != nil {
+		return false, 0, err
+	}
+
+	var units float64
+	if output.ConsumedCapacity != nil {
+		units = capacityUnits(*output.ConsumedCapacity)
+	}
+
+	return true, units, nil
+}
+
+// openImport opens the file to import, where a path of - means STDIN, and
+// an s3:// URL is read from S3.
+func openImport(ctx context.Context, cfg aws.Config, path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return os.Stdin, nil
+	}
+
+	if strings.HasPrefix(path, "s3://") {
+		return openS3Object(ctx, cfg, path)
+	}
+
+	return os.Open(path)
+}
+
+// importTable returns the table items are imported into, which is the
+// --table unless --target-table is given.
+func importTable() string {
+	if targetTable != "" {
+		return targetTable
+	}
+
+	return tableName
+}
+
+// validate marshals every item without writing anything, reporting each item
+// that could not be marshaled.
+func validate(reader itemReader, target string) error {
+	count, failed, err := checkItems(reader)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "DRY RUN: %d items would be imported into %s\n", count, target)
+
+	if failed > 0 {
+		return fmt.Errorf("%d items failed validation", failed)
+	}
+
+	return nil
+}
+
+// validateFile checks the structure of the file and every item in it,
+// without connecting to the table.
+func validateFile(ctx context.Context, cfg aws.Config, path string) error {
+	paths, err := importPaths(path)
+	if err != nil {
+		return err
+	}
+
+	err = verifyChecksums(paths)
+	if err != nil {
+		return err
+	}
+
+	reader, err := openItemReader(ctx, cfg, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	count, failed, err := checkItems(reader)
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items failed validation", failed, count+failed)
+	}
+
+	successf("%s is valid, with %d items", path, count)
+	return nil
+}
+
+// checkItems reads every item, logging those which cannot be imported. It
+// returns the number of valid and invalid items.
+func checkItems(reader itemReader) (int, int, error) {
+	var count, failed int
+	for index := 0; ; index++ {
+		item, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+
+		err = transformItem(item)
+		if err == nil {
+			_, err = marshalPlainItem(item)
+		}
+		if err != nil {
+			errorf("item %d: %s", index, err)
+			failed++
+			continue
+		}
+
+		count++
+	}
+
+	return count, failed, nil
+}
+
+// decompress transparently unwraps encrypted and gzip compressed input, which
+// are detected by their magic bytes rather than relying on the file
+// extension.
+func decompress(r *bufio.Reader) (io.Reader, error) {
+	encrypted, err := isEncrypted(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !encrypted && decryptInput {
+		return nil, errors.New("the input is not encrypted, but --decrypt was given")
+	}
+
+	if encrypted {
+		decrypted, err := newDecryptReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		r = bufio.NewReader(decrypted)
+	}
+
+	magic, err := r.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(r)
+	}
+
+	return r, nil
+}
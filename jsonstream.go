@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// jsonStream writes the json format one item at a time, so that the items
+// never have to be held in memory. The output is byte for byte the same as
+// encoding the whole exportFormat with a json.Encoder, indented if pretty, or
+// just the items with --no-metadata.
+type jsonStream struct {
+	w      io.Writer
+	pretty bool
+	bare   bool
+	count  int
+}
+
+// newJSONStream writes the metadata, up to the start of the items.
+func newJSONStream(w io.Writer, metadata tableMetadata, pretty bool) (*jsonStream, error) {
+	s := &jsonStream{w: w, pretty: pretty, bare: noMetadata}
+
+	if s.bare {
+		_, err := io.WriteString(w, "[")
+		return s, err
+	}
+
+	header, err := s.marshal(metadata, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the closing brace of the metadata, so that the items can be
+	// added as its last field.
+	header = bytes.TrimSuffix(header, []byte("}"))
+	header = bytes.TrimRight(header, "\n")
+
+	if pretty {
+		header = append(header, ",\n  \"Items\": ["...)
+	} else {
+		header = append(header, `,"Items":[`...)
+	}
+
+	_, err = w.Write(header)
+	return s, err
+}
+
+func (s *jsonStream) marshal(value any, prefix string) ([]byte, error) {
+	if s.pretty {
+		return json.MarshalIndent(value, prefix, "  ")
+	}
+
+	return json.Marshal(value)
+}
+
+// indent is the indentation of each item, which is one level less without
+// the metadata around them.
+func (s *jsonStream) indent() string {
+	if s.bare {
+		return "  "
+	}
+
+	return "    "
+}
+
+func (s *jsonStream) write(item map[string]any) error {
+	raw, err := s.marshal(item, s.indent())
+	if err != nil {
+		return err
+	}
+
+	var separator string
+	if s.count > 0 {
+		separator = ","
+	}
+
+	if s.pretty {
+		separator += "\n" + s.indent()
+	}
+
+	s.count++
+
+	_, err = io.WriteString(s.w, separator)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(raw)
+	return err
+}
+
+// close ends the items and the object they are in.
+func (s *jsonStream) close() error {
+	if s.bare {
+		end := "]\n"
+		if s.pretty && s.count > 0 {
+			end = "\n" + end
+		}
+
+		_, err := io.WriteString(s.w, end)
+		return err
+	}
+
+	end := "]}\n"
+	if s.pretty {
+		end = "]\n}\n"
+		if s.count > 0 {
+			end = "\n  " + end
+		}
+	}
+
+	_, err := io.WriteString(s.w, end)
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"golang.org/x/time/rate"
+)
+
+// newWriteLimiter returns a limiter which allows maxWCU write capacity units
+// per second, or nil if writes should not be limited.
+func newWriteLimiter() *rate.Limiter {
+	if maxWCU <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(maxWCU), maxWCU)
+}
+
+// newItemLimiter returns a limiter which allows --max-items-per-second items
+// to be written per second regardless of their size, or nil if the number of
+// items should not be limited. Items are limited as they are queued for the
+// batch writers, so the limit is shared by every --concurrency worker.
+func newItemLimiter() *rate.Limiter {
+	if maxItemsPerSecond <= 0 {
+		return nil
+	}
+
+	return rate.NewLimiter(rate.Limit(maxItemsPerSecond), maxItemsPerSecond)
+}
+
+// writeUnits is the number of write capacity units needed to write an item
+// of the given size, which is one per started kilobyte.
+func writeUnits(size int) int {
+	return max(1, (size+1023)/1024)
+}
+
+// capacityUnits totals the capacity units reported as consumed by a request.
+func capacityUnits(consumed ...types.ConsumedCapacity) float64 {
+	var units float64
+	for _, c := range consumed {
+		units += aws.ToFloat64(c.CapacityUnits)
+	}
+
+	return units
+}
+
+// waitForCapacity blocks until the limiter allows the given number of
+// capacity units, or items, to be consumed. Items can need more units than
+// the limiter allows in a single second, so large requests are waited for in
+// chunks.
+func waitForCapacity(ctx context.Context, limiter *rate.Limiter, units int) error {
+	if limiter == nil {
+		return nil
+	}
+
+	for units > 0 {
+		n := min(units, limiter.Burst())
+
+		err := limiter.WaitN(ctx, n)
+		if err != nil {
+			return err
+		}
+
+		units -= n
+	}
+
+	return nil
+}
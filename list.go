@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/dustin/go-humanize"
+)
+
+// listTables prints the name of every table in the region, one per line.
+// With --verbose, each is described to add its approximate item count and
+// size.
+func listTables(ctx context.Context, client dynamoDBAPI, w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	paginator := dynamodb.NewListTablesPaginator(client, &dynamodb.ListTablesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range page.TableNames {
+			if !verbose {
+				fmt.Fprintln(tw, name)
+				continue
+			}
+
+			table, err := describeTable(ctx, client, name)
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(tw, "%s\t%s items\t%s\n", name, humanize.Comma(aws.ToInt64(table.ItemCount)), humanize.Bytes(uint64(aws.ToInt64(table.TableSizeBytes))))
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+
+	"github.com/aws/smithy-go/logging"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// stderr styles messages written to stderr. Colours are only used when it
+// is a terminal and NO_COLOR is not set.
+var stderr = lipgloss.NewRenderer(os.Stderr)
+
+var (
+	warningStyle = stderr.NewStyle().Foreground(lipgloss.Color("3"))
+	errorStyle   = stderr.NewStyle().Foreground(lipgloss.Color("1"))
+	successStyle = stderr.NewStyle().Foreground(lipgloss.Color("2"))
+)
+
+// setupLogging switches to structured logging with debug messages when
+// --verbose is set. The log package is routed through the same handler, so
+// every message ends up in the same format on stderr.
+func setupLogging() {
+	if !verbose {
+		return
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})))
+}
+
+// sdkLogger passes messages from the AWS SDK, such as retry attempts, on to
+// slog.
+type sdkLogger struct{}
+
+func (sdkLogger) Logf(classification logging.Classification, format string, v ...any) {
+	level := slog.LevelDebug
+	if classification == logging.Warn {
+		level = slog.LevelWarn
+	}
+
+	slog.Log(context.Background(), level, fmt.Sprintf(format, v...), "source", "aws-sdk")
+}
+
+// warnf logs something the user should know about, such as items being
+// skipped, in yellow.
+func warnf(format string, v ...any) {
+	logStyled(slog.LevelWarn, warningStyle, format, v...)
+}
+
+// errorf logs an error in red.
+func errorf(format string, v ...any) {
+	logStyled(slog.LevelError, errorStyle, format, v...)
+}
+
+// successf logs the summary of a finished export or import in green.
+func successf(format string, v ...any) {
+	logStyled(slog.LevelInfo, successStyle, format, v...)
+}
+
+// fatalf logs an error and exits.
+func fatalf(format string, v ...any) {
+	errorf(format, v...)
+	os.Exit(1)
+}
+
+// logStyled logs the message in the style, or at the level when --verbose
+// switches to structured logging. Without colours, warnings and errors are
+// labelled instead, so that they still stand out in logs and CI output.
+func logStyled(level slog.Level, style lipgloss.Style, format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	if verbose {
+		slog.Log(context.Background(), level, msg)
+		return
+	}
+
+	if stderr.ColorProfile() == termenv.Ascii {
+		switch level {
+		case slog.LevelWarn:
+			msg = "WARNING: " + msg
+		case slog.LevelError:
+			msg = "ERROR: " + msg
+		}
+	}
+
+	log.Print(style.Render(msg))
+}
@@ -1,32 +1,295 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"math/rand/v2"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/ratelimit"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-	"github.com/charmbracelet/huh"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/dustin/go-humanize"
 )
 
 var importPath string
+var putValue string
+var deletePath string
+var copyTo string
+var targetRegion string
+var targetProfile string
+var targetAssumeRole string
+var diffPath string
+var countOnly bool
+var describeOnly bool
+var listOnly bool
+var schemaOnly bool
+var convertPath string
+var convertFrom string
+var convertTo string
+var outputPath string
+var s3Path string
+var nativeExportPath string
 var exporter bool
 var tableName string
+var tables string
+var segments = 1
+var autoSegments bool
+var format string
+var region string
+var profile string
+var assumeRole string
+var externalID string
+var roleSessionName string
+var endpointURL string
+var maxRetries int
+var retryMode string
+var retryTokenRate uint
+var httpTimeout time.Duration
+var gzipOutput bool
+var encryptOutput bool
+var decryptInput bool
+var passphraseFile string
+var pretty bool
+var checkpointPath string
+var verbose bool
+var filter string
+var filterValues string
+var modifiedSince string
+var modifiedUntil string
+var timestampAttribute string
+var attributes string
+var keysOnly bool
+var queryKey string
+var indexName string
+var queryRange string
+var getKey string
+var getSortKey string
+var consistentRead bool
+var limit int
+var pageSize int
+var sampleRate float64
+var seed uint64
+var timeout time.Duration
+var dryRun bool
+var validateOnly bool
+var assumeYes bool
+var targetTable string
+var truncateTable bool
+var createMissingTable bool
+var ifNotExists bool
+var importMode string
+var conditionalOn string
+var verifyImport bool
+var skipExpired bool
+var skipOversized bool
+var skipInvalid bool
+var continueOnError bool
+var renames []rename
+var dropAttributes []string
+var excludeAttributes []string
+var defaults []attributeDefault
+var transforms []transform
+var errorFile string
+var dedup string
+var strictDuplicates bool
+var maxWCU int
+var maxItemsPerSecond int
+var concurrency int
+var batchSize int
+var splitSize int64
+var configFile string
+var showVersion bool
+var checksum bool
+var verifyChecksum bool
+var progressFile string
+var metadataFile string
+var noMetadata bool
+
+// The build is described by these, which are set with -ldflags, such as
+// -X main.version=v1.2.0.
+var version = "dev"
+var commit = "unknown"
+var buildDate = "unknown"
 
 func init() {
-	flag.StringVar(&tableName, "table", "", "Specify the tableName")
-	flag.StringVar(&importPath, "import", "", "Import data from a file in JSON format")
+	flag.StringVar(&configFile, "config", "", "YAML or JSON file of settings, keyed by flag name, which flags on the command line override")
+	flag.StringVar(&tableName, "table", "", "Specify the tableName, which defaults to $DDBM_TABLE")
+	flag.BoolVar(&showVersion, "version", false, "Print the version of ddbm and exit")
+	flag.StringVar(&tables, "tables", "", "Comma separated list of tables to export, each to its own file")
+	flag.StringVar(&region, "region", "", "AWS region to use, overriding the default configuration")
+	flag.StringVar(&profile, "profile", "", "Named AWS profile from the shared configuration to use")
+	flag.StringVar(&assumeRole, "assume-role", "", "ARN of an IAM role to assume, such as to access a table in another account")
+	flag.StringVar(&externalID, "external-id", "", "External ID to pass when assuming --assume-role or --target-assume-role")
+	flag.StringVar(&roleSessionName, "role-session-name", "ddbm", "Session name to use when assuming --assume-role or --target-assume-role")
+	flag.StringVar(&endpointURL, "endpoint-url", "", "Custom DynamoDB endpoint, such as http://localhost:8000 for DynamoDB Local")
+	flag.DurationVar(&timeout, "timeout", 0, "Give up if the export or import has not finished within this duration, such as 30m")
+	flag.IntVar(&maxRetries, "max-retries", 10, "Maximum number of times to retry a throttled or failed request, or a batch with unprocessed items")
+	flag.StringVar(&retryMode, "retry-mode", string(aws.RetryModeStandard), "Retry strategy to use, either standard or adaptive")
+	flag.DurationVar(&httpTimeout, "http-timeout", 0, "Fail and retry any single request to AWS which takes longer than this to respond, such as 30s")
+	flag.UintVar(&retryTokenRate, "retry-token-rate", retry.DefaultRetryRateTokens, "Size of the client-side retry token bucket, which each retry draws from and each success refills, or 0 for no limit")
+	flag.Func("segments", "Number of parallel segments to scan the table with, or auto to pick one for every GB in the table (default 1)", func(value string) error {
+		if value == "auto" {
+			autoSegments = true
+			return nil
+		}
+
+		n, err := strconv.Atoi(value)
+		segments = n
+		return err
+	})
+	flag.StringVar(&format, "format", formatJSON, "Format of the export or import data, either json, jsonl or csv, or dynamodb-json for imports only")
+	flag.BoolVar(&pretty, "pretty", false, "Indent the exported JSON so that it is easier to read, in the json format only")
+	flag.BoolVar(&gzipOutput, "gzip", false, "Compress the export with gzip")
+	flag.BoolVar(&encryptOutput, "encrypt", false, "Encrypt the export with AES-256-GCM, using a key derived from the passphrase")
+	flag.BoolVar(&decryptInput, "decrypt", false, "Require the import to be encrypted, rather than importing a file which is not as it is")
+	flag.StringVar(&passphraseFile, "passphrase-file", "", "File containing the passphrase for --encrypt, or for an encrypted import, which is detected from its header. Defaults to $DDBM_PASSPHRASE")
+	flag.StringVar(&checkpointPath, "checkpoint", "", "Save export progress to this file, and resume from it if it exists")
+	flag.StringVar(&filter, "filter", "", "Only export items matching this filter expression")
+	flag.StringVar(&modifiedSince, "modified-since", "", "Only export items whose --timestamp-attr is at or after this time, in RFC 3339 or seconds since the epoch")
+	flag.StringVar(&modifiedUntil, "modified-until", "", "Only export items whose --timestamp-attr is before this time, in RFC 3339 or seconds since the epoch")
+	flag.StringVar(&timestampAttribute, "timestamp-attr", "updatedAt", "Attribute holding when each item was last modified, in seconds since the epoch, for --modified-since and --modified-until")
+	flag.StringVar(&filterValues, "filter-values", "", "JSON object of the values used in --filter, such as '{\":status\": \"active\"}'")
+	flag.StringVar(&attributes, "attributes", "", "Comma separated list of the attributes to export, instead of every attribute")
+	flag.StringVar(&indexName, "index", "", "Export the items in this secondary index, with its projection, instead of the table")
+	flag.StringVar(&queryKey, "query-key", "", "Only export the items with this partition key value, using a query rather than scanning the table")
+	flag.StringVar(&queryRange, "query-range", "", "Condition on the sort key for --query-key, such as 'begins_with(#sk, :prefix)' where #sk is the sort key, with values given by --filter-values")
+	flag.BoolVar(&keysOnly, "keys-only", false, "Only export the key attributes of each item")
+	flag.StringVar(&getKey, "get", "", "Print only the item with this partition key value, using GetItem rather than scanning the table")
+	flag.StringVar(&getSortKey, "get-sort", "", "Sort key value of the item to print with --get, for tables with a sort key")
+	flag.Func("exclude-attr", "Comma separated list of glob patterns, such as secret_*, for top-level attributes to leave out of every exported item. Can be given more than once", func(value string) error {
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.TrimSpace(pattern)
+
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("%s: %w", pattern, err)
+			}
+
+			excludeAttributes = append(excludeAttributes, pattern)
+		}
+		return nil
+	})
+	flag.BoolVar(&consistentRead, "consistent-read", false, "Use strongly consistent reads when exporting, at twice the read capacity cost")
+	flag.BoolVar(&verbose, "verbose", false, "Log every scanned page, written batch and retry to STDERR")
+	flag.IntVar(&limit, "limit", 0, "Stop exporting once this many items have been exported")
+	flag.IntVar(&pageSize, "page-size", 0, "Maximum number of items to read in each page of the scan, rather than up to 1MB of items")
+	flag.Float64Var(&sampleRate, "sample-rate", 1, "Export a random sample of the table, keeping each item with this probability between 0 and 1")
+	flag.Uint64Var(&seed, "seed", 0, "Seed for --sample-rate, so that the same sample is exported every time. Defaults to a random seed, which is logged")
+	flag.StringVar(&outputPath, "output", "", "Write the export to this file instead of STDOUT")
+	flag.StringVar(&s3Path, "s3", "", "Upload the export to an S3 object, given as s3://bucket/key")
+	flag.StringVar(&nativeExportPath, "native-export", "", "Have DynamoDB export the table to an S3 prefix itself, given as s3://bucket/prefix, which requires point in time recovery")
+	flag.StringVar(&importPath, "import", "", "Import data from a file in JSON format, from an S3 object given as s3://bucket/key, or from STDIN if set to -")
+	flag.StringVar(&putValue, "put", "", "Import a single item given as a JSON object, such as '{\"id\": \"123\"}', without a file")
+	flag.BoolVar(&countOnly, "count", false, "Print the number of items in the table, without exporting any of them")
+	flag.BoolVar(&listOnly, "list", false, "Print the name of every table in the region, with its item count and size if --verbose is set")
+	flag.BoolVar(&describeOnly, "describe", false, "Print a summary of the table's keys, indexes, capacity, TTL and tags, without exporting any items")
+	flag.BoolVar(&schemaOnly, "schema-only", false, "Export just the schema of the table, without scanning any items")
+	flag.StringVar(&diffPath, "diff", "", "Compare the items in a file with those in the table, and report the differences")
+	flag.StringVar(&copyTo, "copy-to", "", "Copy every item from the table straight into this table, without an intermediate file")
+	flag.StringVar(&targetRegion, "target-region", "", "AWS region of the --copy-to table, if it differs from --region")
+	flag.StringVar(&targetProfile, "target-profile", "", "Named AWS profile to write to the --copy-to table with, if it differs from --profile")
+	flag.StringVar(&targetAssumeRole, "target-assume-role", "", "ARN of an IAM role to assume to write to the --copy-to table, if it differs from --assume-role")
+	flag.StringVar(&deletePath, "delete", "", "Delete every item listed in a file from the table, using only their key attributes")
+	flag.StringVar(&convertPath, "convert", "", "Convert a local file, or STDIN if set to -, from the --from format to the --to format without connecting to DynamoDB")
+	flag.StringVar(&convertFrom, "from", formatJSON, "Format of the file given to --convert")
+	flag.StringVar(&convertTo, "to", formatJSON, "Format to write with --convert")
+	flag.StringVar(&targetTable, "target-table", "", "Import into this table instead of the one given by --table")
+	flag.BoolVar(&createMissingTable, "create-table", false, "Create the table from the schema in the import file if it does not exist")
+	flag.BoolVar(&truncateTable, "truncate", false, "Delete all existing items from the table before importing")
+	flag.StringVar(&importMode, "mode", modeOverwrite, "How to import items which already exist in the table, either overwrite, skip-existing or fail-on-conflict")
+	flag.StringVar(&conditionalOn, "conditional-on", "", "Only overwrite an existing item if this attribute, such as a version or timestamp, is greater in the imported item")
+	flag.BoolVar(&ifNotExists, "if-not-exists", false, "Only import items which do not already exist in the table, the same as --mode skip-existing")
+	flag.IntVar(&concurrency, "concurrency", 1, "Number of batches to write in parallel when importing")
+	flag.IntVar(&batchSize, "batch-size", maxBatchSize, "Number of items to write in each batch when importing, at most 25")
+	flag.IntVar(&maxWCU, "max-wcu", 0, "Limit imports to this many write capacity units per second")
+	flag.IntVar(&maxItemsPerSecond, "max-items-per-second", 0, "Limit imports to this many items per second, however large they are")
+	flag.BoolVar(&skipExpired, "skip-expired", false, "Skip items whose TTL attribute is already in the past, since DynamoDB would delete them anyway")
+	flag.BoolVar(&skipOversized, "skip-oversized", false, "Skip items over the 400KB DynamoDB allows with a warning, instead of stopping the import")
+	flag.BoolVar(&skipInvalid, "skip-invalid", false, "Skip items which are missing a key attribute of the table with a warning, instead of stopping the import")
+	flag.BoolVar(&continueOnError, "continue-on-error", false, "Carry on importing when items fail, and report them all at the end")
+	flag.StringVar(&errorFile, "error-file", "", "With --continue-on-error, write the items which failed to this file in the jsonl format")
+	flag.StringVar(&dedup, "dedup", "", "Only import one of the items which have the same key, either the first or the last")
+	flag.BoolVar(&strictDuplicates, "strict", false, "Fail the import if more than one item has the same key")
+	flag.BoolVar(&verifyImport, "verify", false, "Count the items in the table after importing, and warn if it does not match the file")
+	flag.BoolVar(&assumeYes, "yes", false, "Import without asking for confirmation first")
+	flag.BoolVar(&checksum, "checksum", false, "Write the checksum and number of items of the export to a .sha256 manifest next to the file")
+	flag.BoolVar(&verifyChecksum, "verify-checksum", false, "Fail the import unless every file matches its .sha256 manifest, which is otherwise only checked if it exists")
+	flag.StringVar(&metadataFile, "metadata-file", "", "Keep the table metadata of the jsonl format in this file, rather than on the first line, so that every line is an item")
+	flag.BoolVar(&noMetadata, "no-metadata", false, "Export only the items, as a bare JSON array or jsonl with no metadata line, and import jsonl files without one")
+	flag.StringVar(&progressFile, "progress-file", "", "Record the key of each imported item in this file, so that an interrupted import can be run again and skip them")
+	flag.BoolVar(&dryRun, "dry-run", false, "Validate the import or --delete file without writing or deleting any items")
+	flag.BoolVar(&validateOnly, "validate-only", false, "Check the import file is well formed and exit, without connecting to the table")
+	flag.Func("split-size", "Split a jsonl export into numbered files of about this size before compression, such as 100MB", func(value string) error {
+		size, err := humanize.ParseBytes(value)
+		splitSize = int64(size)
+		return err
+	})
+	flag.Func("rename", "Rename a top-level attribute of every imported item, given as old=new. Can be given more than once", func(value string) error {
+		r, err := parseRename(value)
+		renames = append(renames, r)
+		return err
+	})
+	flag.Func("drop", "Comma separated list of top-level attributes to remove from every imported item", func(value string) error {
+		for _, name := range strings.Split(value, ",") {
+			dropAttributes = append(dropAttributes, strings.TrimSpace(name))
+		}
+		return nil
+	})
+	flag.Func("default", "Add an attribute to every imported item which does not have it, given as name=value, or name:N=value for a number. Can be given more than once", func(value string) error {
+		d, err := parseDefault(value)
+		defaults = append(defaults, d)
+		return err
+	})
+	flag.Func("transform", "Set an attribute of every imported item to an expression of its other attributes, given as name = expression. Can be given more than once", func(value string) error {
+		t, err := parseTransform(value)
+		transforms = append(transforms, t)
+		return err
+	})
+}
+
+// parseFlags parses the command line, and then fills in anything not given
+// on it from --config and the environment. It is called from main rather
+// than init, so that test binaries can parse their own flags.
+func parseFlags() {
 	flag.Parse()
+
+	// Parsing stops at the first argument which is not a flag, so anything
+	// left over would mean the flags after it were silently ignored.
+	if flag.NArg() > 0 {
+		fatalf("unexpected argument %q, as every option is given as a flag", flag.Arg(0))
+	}
+
+	if configFile != "" {
+		err := applyConfigFile(configFile)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	// The environment is only a fallback, such as for jobs in containers,
+	// so it does not apply when listing tables or exporting several.
+	if tableName == "" && tables == "" && !listOnly {
+		tableName = os.Getenv("DDBM_TABLE")
+	}
 }
 
 func usage() {
-	fmt.Println(`
+	fmt.Print(`
 DynamoDB Migrator
 =================
 
@@ -38,40 +301,832 @@ This will print to STDOUT, so direct the output to a file:
 
 ddbm --table foo > /path/to/file.json
 
+Or write it to a file directly:
+
+ddbm --table foo --output /path/to/file.json
+
+Or upload it straight to S3, using the same credentials:
+
+ddbm --table foo --s3 s3://bucket/backups/foo.json
+
+Very large tables can instead be exported by DynamoDB itself, which consumes
+no read capacity but requires point in time recovery to be enabled. This
+writes AWS's own export format, which is imported with --format dynamodb-json:
+
+ddbm --table foo --native-export s3://bucket/backups/foo
+
+To count the items in a table without exporting them, since the item count
+DynamoDB reports is only updated every few hours:
+
+ddbm --table foo --count --segments 8
+
+To list the tables in a region, adding their approximate size with --verbose:
+
+ddbm --list --region eu-west-1
+
+To see a summary of a table, such as its keys, indexes and TTL:
+
+ddbm --table foo --describe
+
+To export just the schema of a table, such as to create an empty copy of it
+by importing the file with --create-table:
+
+ddbm --table foo --schema-only --output /path/to/schema.json
+
+Several tables can be exported at once, each to a file named after the table
+in the given directory or S3 prefix:
+
+ddbm --tables foo,bar,baz --output /path/to/backups
+ddbm --tables foo,bar,baz --s3 s3://bucket/backups/
+
+To write a .sha256 manifest next to the export, with its checksum and number
+of items. Imports check files against their manifest if there is one, or
+always with --verify-checksum:
+
+ddbm --table foo --output /path/to/file.json --checksum
+ddbm --table foo --import /path/to/file.json --verify-checksum
+
+Binary attributes and sets, which have no JSON equivalent, are exported as
+objects such as {"$B": "aGVsbG8="} or {"$SS": ["a", "b"]}, so that they are
+imported with the same type again.
+
+To make small exports easier to read and diff:
+
+ddbm --table foo --pretty > /path/to/file.json
+
+Large tables can be scanned in parallel segments:
+
+ddbm --table foo --segments 8 > /path/to/file.json
+
+Or to pick the number of segments from the size of the table:
+
+ddbm --table foo --segments auto > /path/to/file.json
+
+Each page of the scan holds up to 1MB of items. To read smaller pages, such
+as to even out the load on a provisioned table:
+
+ddbm --table foo --page-size 100 > /path/to/file.json
+
+To only export items matching a filter expression. Attribute names which are
+reserved words can be prefixed with #:
+
+ddbm --table foo --filter '#status = :status' --filter-values '{":status": "active"}'
+
+For incremental backups, export only the items modified in a window, going by
+an attribute holding seconds since the epoch. Items modified at exactly the
+end of the window are left for the next one:
+
+ddbm --table foo --modified-since 2024-06-01T00:00:00Z --modified-until 2024-06-02T00:00:00Z --timestamp-attr updatedAt
+
+To only export a single partition, which queries the table rather than
+scanning all of it, optionally with a condition on the sort key, which is
+named by #sk whatever it is called:
+
+ddbm --table foo --query-key customer-123
+ddbm --table foo --query-key customer-123 --query-range 'begins_with(#sk, :prefix)' --filter-values '{":prefix": "order#"}'
+
+To print a single item by its key, such as when debugging, which reads just
+that item rather than scanning the table:
+
+ddbm --table foo --get customer-123
+ddbm --table foo --get customer-123 --get-sort order#42 --pretty
+
+To export the items as they appear in a secondary index, with only the
+attributes it projects. The index keys are recorded as the keys of the
+export, and can be used with --query-key:
+
+ddbm --table foo --index by-email > /path/to/file.json
+
+To only export some attributes of each item:
+
+ddbm --table foo --attributes id,name,email
+
+Or to leave out attributes matching glob patterns, such as to scrub sensitive
+fields before sharing an export:
+
+ddbm --table foo --exclude-attr 'secret_*,password' > /path/to/file.json
+
+Or only their keys, such as to build a list of items to --delete:
+
+ddbm --table foo --keys-only > /path/to/keys.json
+
+To make sure the export includes every write made before it started:
+
+ddbm --table foo --consistent-read
+
+To only export the first 100 items, such as for a test data set:
+
+ddbm --table foo --limit 100
+
+Or to export a random 1% of the items, which is the same every time for the
+same seed:
+
+ddbm --table foo --sample-rate 0.01 --seed 42
+
+Tables too large to hold in memory can be streamed as newline-delimited JSON:
+
+ddbm --table foo --format jsonl > /path/to/file.jsonl
+
+The first line holds the table metadata. To write it to its own file instead,
+so that every line is an item, such as to pipe the export into jq, and to
+import it again:
+
+ddbm --table foo --format jsonl --metadata-file foo.metadata.json | jq .status
+ddbm --table foo --format jsonl --metadata-file foo.metadata.json --import /path/to/file.jsonl
+
+Or to leave the metadata out altogether, for tools which only want the items,
+as a bare JSON array or jsonl with no metadata line. Both are imported too:
+
+ddbm --table foo --no-metadata > /path/to/items.json
+ddbm --table foo --format jsonl --no-metadata > /path/to/items.jsonl
+ddbm --table foo --format jsonl --no-metadata --import /path/to/items.jsonl
+
+For spreadsheets, tables can be exported as CSV with a column per attribute.
+Nested maps, lists and sets are written as embedded JSON, and are decoded
+again on import:
+
+ddbm --table foo --format csv > /path/to/file.csv
+
+Long running jsonl exports can be resumed if they are interrupted, by running
+the same command again. The output file is appended to when resuming:
+
+ddbm --table foo --format jsonl --checkpoint foo.checkpoint --output /path/to/file.jsonl
+
+Large jsonl exports can be split into numbered files, such as
+/path/to/foo.001.jsonl, which can be imported together with a glob or by
+giving the directory they are in:
+
+ddbm --table foo --format jsonl --split-size 100MB --output /path/to/foo.jsonl
+ddbm --table foo --format jsonl --import '/path/to/foo.*.jsonl'
+
+Exports can be compressed, and compressed files are detected on import:
+
+ddbm --table foo --gzip > /path/to/file.json.gz
+
+Exports can also be encrypted, such as for backups kept in shared storage,
+and then decrypted on import with the same passphrase. It is read from
+--passphrase-file, or otherwise from $DDBM_PASSPHRASE, but never from the
+command line where other users could see it. Encrypted files are detected
+on import, and --decrypt makes sure that nothing else is imported:
+
+ddbm --table foo --gzip --encrypt --passphrase-file /path/to/passphrase > /path/to/file.json.gz.enc
+ddbm --table foo --import /path/to/file.json.gz.enc --decrypt --passphrase-file /path/to/passphrase
+
+To use DynamoDB Local, or any other compatible endpoint:
+
+ddbm --table foo --endpoint-url http://localhost:8000
+
 To import:
 
 ddbm --table foo --import /path/to/file.json
+
+To migrate between regions or accounts, use --region and --profile on
+either side:
+
+ddbm --table foo --profile prod --region eu-west-1 > /path/to/file.json
+ddbm --table foo --profile dev --region us-east-1 --import /path/to/file.json
+
+Files exported as jsonl are imported item by item:
+
+ddbm --table foo --format jsonl --import /path/to/file.jsonl
+
+Files in the typed DynamoDB JSON format, such as the output of aws dynamodb
+scan or the files written by --native-export, can also be imported:
+
+ddbm --table foo --format dynamodb-json --import /path/to/scan.json
+
+To convert between formats without connecting to DynamoDB, such as to turn
+the output of aws dynamodb scan into a file for ddbm, or back again:
+
+ddbm --convert /path/to/scan.json --from dynamodb-json --to json > /path/to/file.json
+ddbm --convert /path/to/file.json --to dynamodb-json > /path/to/items.json
+
+To restore an export from S3:
+
+ddbm --table foo --import s3://bucket/backups/foo.json
+
+To copy a table without an intermediate file, import from STDIN:
+
+ddbm --table foo | ddbm --table bar --import -
+
+To copy a table into another one directly, which can be in a different region
+or account:
+
+ddbm --table foo --copy-to bar
+ddbm --table foo --profile prod --copy-to foo --target-profile dev --target-region us-east-1
+
+Or to assume a role in each account instead:
+
+ddbm --table foo --assume-role arn:aws:iam::111111111111:role/export --copy-to foo --target-assume-role arn:aws:iam::222222222222:role/import
+
+Files holding just a JSON array of items, such as from another tool, can be
+imported too, into the table given by --table:
+
+ddbm --table foo --import /path/to/items.json
+
+To import into a differently named table:
+
+ddbm --table foo --import /path/to/file.json --target-table bar
+
+To create the table from the exported schema if it does not exist yet, along
+with its indexes, capacity, TTL and tags:
+
+ddbm --table foo --import /path/to/file.json --create-table
+
+To remove every existing item first, so the table exactly matches the file:
+
+ddbm --table foo --import /path/to/file.json --truncate
+
+Large tables are truncated faster by scanning them in segments, and deleting
+with more concurrent writers:
+
+ddbm --table foo --import /path/to/file.json --truncate --segments 8 --concurrency 16
+
+Existing items with the same key are overwritten by default. To leave them
+untouched instead:
+
+ddbm --table foo --import /path/to/file.json --mode skip-existing
+
+Or to stop the import as soon as an item already exists:
+
+ddbm --table foo --import /path/to/file.json --mode fail-on-conflict
+
+Or to only overwrite existing items which are older than the imported item,
+going by a version or timestamp attribute:
+
+ddbm --table foo --import /path/to/file.json --conditional-on updatedAt
+
+To write a single item without a file, such as for a quick fix, with the same
+checks and confirmation as an import:
+
+ddbm --table foo --put '{"id": "123", "name": "x"}'
+
+To see how a table has changed since it was exported, before importing it
+again. Add --verbose to list the key of every item which differs:
+
+ddbm --table foo --diff /path/to/file.json
+
+To roll back an import, delete every item in the file from the table:
+
+ddbm --table foo --delete /path/to/file.json
+
+Or to check how many keys would be deleted, without deleting anything:
+
+ddbm --table foo --delete /path/to/file.json --dry-run
+
+Throttled requests are retried with backoff. For tables that are heavily
+throttled, retry harder and let the client limit its own request rate:
+
+ddbm --table foo --import /path/to/file.json --max-retries 20 --retry-mode adaptive
+
+The standard mode only backs off before retrying each throttled request,
+which keeps up the most throughput, but can make throttling worse on a table
+running near its capacity. The adaptive mode also slows down every request
+once throttling starts, which keeps throughput steadier at the cost of some
+speed, and recovers once the throttling stops.
+
+In both modes, each retry draws tokens from a bucket that successful
+requests refill, and requests fail rather than being retried once it is
+empty. Migrations which expect a lot of throttling can use a bigger bucket,
+or no limit at all:
+
+ddbm --table foo --import /path/to/file.json --retry-mode adaptive --retry-token-rate 0
+
+On unreliable networks, a single request can hang for a long time. To give
+up on one which has had no response, and retry it sooner:
+
+ddbm --table foo --http-timeout 30s
+
+To write several batches in parallel on tables with plenty of capacity:
+
+ddbm --table foo --import /path/to/file.json --concurrency 8
+
+Or to write smaller batches, such as when items are close to the 400KB limit
+and a full batch would be over the 16MB request limit:
+
+ddbm --table foo --import /path/to/file.json --batch-size 10
+
+To leave capacity free for other traffic on a provisioned table, limit the
+write capacity units consumed per second:
+
+ddbm --table foo --import /path/to/file.json --max-wcu 100
+
+Or to simply go slow, limit the number of items written per second across
+every concurrent writer:
+
+ddbm --table foo --import /path/to/file.json --max-items-per-second 50
+
+When restoring an old backup to a table with TTL enabled, items which have
+already expired can be left out:
+
+ddbm --table foo --import /path/to/file.json --skip-expired
+
+Items over the 400KB limit, or which are missing a key attribute, stop the
+import. To skip them with a warning instead:
+
+ddbm --table foo --import /path/to/file.json --skip-oversized --skip-invalid
+
+To import every item that can be, rather than stopping at the first that
+fails, and save the failures to retry them later:
+
+ddbm --table foo --import /path/to/file.json --continue-on-error --error-file failed.jsonl
+ddbm --table foo --format jsonl --import failed.jsonl
+
+To be able to resume a large import, record the key of every item written.
+Running the same command again skips the items already imported, and the file
+is removed once the import has finished:
+
+ddbm --table foo --import /path/to/file.json --progress-file foo.progress
+
+To rename attributes while importing, such as after a change to the schema:
+
+ddbm --table foo --import /path/to/file.json --rename userId=user_id --rename createdAt=created_at
+
+Or to leave some attributes out, using their names in the file:
+
+ddbm --table foo --import /path/to/file.json --drop legacyField,tmp
+
+Or to fill in attributes added since the export was taken, where the type can
+be S for a string, N for a number or BOOL for a boolean:
+
+ddbm --table foo --import /path/to/file.json --default status=unknown --default retries:N=0
+
+Or to compute attributes from others, where expressions can use attribute
+names, "strings", numbers, + - * / and the functions concat, upper, lower,
+trim, string and number. Items without the attributes used are left as is:
+
+ddbm --table foo --import /path/to/file.json --transform 'name = concat(first, " ", last)' --transform 'total = price * quantity'
+
+Files merged from several exports can contain more than one item with the
+same key, where the last would silently overwrite the rest. To choose which
+is imported, or to fail instead:
+
+ddbm --table foo --import /path/to/file.json --dedup last
+ddbm --table foo --import /path/to/file.json --strict
+
+To check the number of items in the table matches the file afterwards:
+
+ddbm --table foo --import /path/to/file.json --truncate --verify
+
+Imports ask for confirmation before writing anything. To run them without a
+terminal, such as in CI:
+
+ddbm --table foo --import /path/to/file.json --yes
+
+To check a file can be imported without writing anything:
+
+ddbm --table foo --import /path/to/file.json --dry-run
+
+Or to only check that the file is well formed, without a table:
+
+ddbm --import /path/to/file.json --validate-only
+
+Repeatable migrations can keep their settings in a YAML or JSON file, keyed
+by flag name, with a list for flags which can be given more than once:
+
+table: foo
+import: /path/to/file.json
+concurrency: 8
+rename:
+  - userId=user_id
+
+ddbm --config migration.yaml
+
+Flags given on the command line override those in the file, which in turn
+override the defaults.
+
+The table can also be given by the DDBM_TABLE environment variable, such as in
+a container, which is only used if neither --table nor the file sets it:
+
+DDBM_TABLE=foo ddbm --output /path/to/file.json
 `)
 }
 
 func main() {
-	if tableName == "" {
+	parseFlags()
+	setupLogging()
+
+	if showVersion {
+		fmt.Printf("ddbm %s, commit %s, built %s\n", version, commit, buildDate)
+		os.Exit(0)
+	}
+
+	if tableName == "" && tables == "" && convertPath == "" && !validateOnly && !listOnly {
 		usage()
 		os.Exit(1)
 	}
 
-	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx)
+	if segments < 1 {
+		fatalf("--segments must be at least 1")
+	}
+
+	if concurrency < 1 {
+		fatalf("--concurrency must be at least 1")
+	}
+
+	if batchSize < 1 || batchSize > maxBatchSize {
+		fatalf("--batch-size must be between 1 and %d, the most DynamoDB accepts in a batch", maxBatchSize)
+	}
+
+	if limit < 0 {
+		fatalf("--limit must not be negative")
+	}
+
+	if pageSize < 0 {
+		fatalf("--page-size must not be negative")
+	}
+
+	if sampleRate <= 0 || sampleRate > 1 {
+		fatalf("--sample-rate must be greater than 0 and at most 1")
+	}
+
+	if sampleRate < 1 && seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	if maxRetries < 0 {
+		fatalf("--max-retries must not be negative")
+	}
+
+	if maxWCU < 0 {
+		fatalf("--max-wcu must not be negative")
+	}
+
+	if maxItemsPerSecond < 0 {
+		fatalf("--max-items-per-second must not be negative")
+	}
+
+	if ifNotExists {
+		if importMode != modeOverwrite && importMode != modeSkipExisting {
+			fatalf("--if-not-exists cannot be used with --mode %s", importMode)
+		}
+
+		importMode = modeSkipExisting
+	}
+
+	if importMode != modeOverwrite && importMode != modeSkipExisting && importMode != modeFailOnConflict {
+		fatalf("unknown mode %q", importMode)
+	}
+
+	if conditionalOn != "" && importMode != modeOverwrite {
+		fatalf("--conditional-on cannot be used with --mode %s", importMode)
+	}
+
+	if errorFile != "" && !continueOnError {
+		fatalf("--error-file requires --continue-on-error")
+	}
+
+	if dedup != "" && dedup != keepFirst && dedup != keepLast {
+		fatalf("unknown --dedup %q, must be first or last", dedup)
+	}
+
+	for _, name := range []string{format, convertFrom, convertTo} {
+		if !knownFormat(name) {
+			fatalf("unknown format %q", name)
+		}
+	}
+
+	if convertPath != "" && s3Path != "" {
+		fatalf("--convert cannot be used with --s3, since it does not connect to AWS")
+	}
+
+	if format == formatDynamoDBJSON && importPath == "" && deletePath == "" && diffPath == "" {
+		fatalf("--format dynamodb-json can only be used with --import, --delete or --diff")
+	}
+
+	if queryRange != "" && queryKey == "" {
+		fatalf("--query-range requires --query-key")
+	}
+
+	if autoSegments && checkpointPath != "" {
+		fatalf("--segments auto cannot be used with --checkpoint, since resuming needs the same number of segments")
+	}
+
+	if queryKey != "" && (segments > 1 || autoSegments || tables != "") {
+		fatalf("--query-key cannot be used with --segments or --tables")
+	}
+
+	if keysOnly && attributes != "" {
+		fatalf("--keys-only cannot be used with --attributes")
+	}
+
+	if copyTo != "" && (importPath != "" || deletePath != "" || tables != "") {
+		fatalf("--copy-to can only be used with a single --table")
+	}
+
+	// Copies are written in batches straight from the scan, so none of the
+	// checks made on each imported item apply to them.
+	if copyTo != "" && (dryRun || importMode != modeOverwrite || ifNotExists || conditionalOn != "" || skipExpired) {
+		fatalf("--copy-to cannot be used with --dry-run, --mode, --if-not-exists, --conditional-on or --skip-expired")
+	}
+
+	// Nor are the scanned items changed or checked on the way.
+	if copyTo != "" && (len(renames) > 0 || len(dropAttributes) > 0 || len(defaults) > 0 || len(transforms) > 0 || dedup != "" || strictDuplicates) {
+		fatalf("--copy-to cannot be used with --rename, --drop, --default, --transform, --dedup or --strict")
+	}
+
+	if copyTo != "" && (skipInvalid || skipOversized || continueOnError || errorFile != "" || verifyImport) {
+		fatalf("--copy-to cannot be used with --skip-invalid, --skip-oversized, --continue-on-error, --error-file or --verify")
+	}
+
+	if (targetRegion != "" || targetProfile != "" || targetAssumeRole != "") && copyTo == "" {
+		fatalf("--target-region, --target-profile and --target-assume-role require --copy-to")
+	}
+
+	if diffPath != "" && (importPath != "" || deletePath != "" || tables != "") {
+		fatalf("--diff can only be used with a single --table")
+	}
+
+	if checksum && (outputPath == "" || importPath != "" || convertPath != "") {
+		fatalf("--checksum requires --output, since it is written next to the exported file")
+	}
+
+	if metadataFile != "" && format != formatJSONL && convertFrom != formatJSONL && convertTo != formatJSONL {
+		fatalf("--metadata-file can only be used with the jsonl format")
+	}
+
+	if noMetadata && (metadataFile != "" || schemaOnly) {
+		fatalf("--no-metadata cannot be used with --metadata-file or --schema-only")
+	}
+
+	if progressFile != "" && importPath == "" {
+		fatalf("--progress-file requires --import")
+	}
+
+	if progressFile != "" && truncateTable {
+		fatalf("--progress-file cannot be used with --truncate, which would delete the items a resumed import skips")
+	}
+
+	if verifyChecksum && importPath == "" {
+		fatalf("--verify-checksum requires --import")
+	}
+
+	if listOnly && (tableName != "" || tables != "" || importPath != "" || deletePath != "") {
+		fatalf("--list cannot be used with --table, --tables, --import or --delete")
+	}
+
+	if describeOnly && (importPath != "" || deletePath != "" || tables != "") {
+		fatalf("--describe can only be used with a single --table")
+	}
+
+	if getSortKey != "" && getKey == "" {
+		fatalf("--get-sort requires --get")
+	}
+
+	if getKey != "" && (importPath != "" || deletePath != "" || tables != "" || describeOnly || countOnly || nativeExportPath != "") {
+		fatalf("--get can only be used with a single --table")
+	}
+
+	if countOnly && (importPath != "" || deletePath != "" || tables != "") {
+		fatalf("--count can only be used with a single --table")
+	}
+
+	if schemaOnly && (importPath != "" || deletePath != "" || format == formatCSV) {
+		fatalf("--schema-only can only be used for exports in the json or jsonl formats")
+	}
+
+	if validateOnly && importPath == "" {
+		fatalf("--validate-only requires --import")
+	}
+
+	if nativeExportPath != "" && (importPath != "" || deletePath != "" || tables != "") {
+		fatalf("--native-export can only be used to export a single --table")
+	}
+
+	if nativeExportPath != "" && len(excludeAttributes) > 0 {
+		fatalf("--exclude-attr cannot be used with --native-export, which DynamoDB writes itself")
+	}
+
+	if putValue != "" && (importPath != "" || deletePath != "" || tables != "" || getKey != "" || describeOnly || countOnly || truncateTable) {
+		fatalf("--put can only be used to write a single item to a single --table")
+	}
+
+	if deletePath != "" && importPath != "" {
+		fatalf("--delete cannot be used with --import")
+	}
+
+	if tables != "" && outputPath == "" && s3Path == "" {
+		fatalf("--tables requires --output or --s3, to give the directory or prefix the tables are exported to")
+	}
+
+	if tables != "" && (importPath != "" || deletePath != "" || checkpointPath != "") {
+		fatalf("--tables can only be used for exports without --checkpoint")
+	}
+
+	if s3Path != "" && outputPath != "" {
+		fatalf("--s3 cannot be used with --output")
+	}
+
+	if s3Path != "" && checkpointPath != "" {
+		fatalf("--s3 cannot be used with --checkpoint, since an S3 object cannot be appended to")
+	}
+
+	if splitSize > 0 && (format != formatJSONL || outputPath == "" || tables != "" || checkpointPath != "") {
+		fatalf("--split-size requires --format jsonl and --output, and cannot be used with --tables or --checkpoint")
+	}
+
+	if checkpointPath != "" && format != formatJSONL {
+		fatalf("--checkpoint requires --format jsonl")
+	}
+
+	if checkpointPath != "" && limit > 0 {
+		fatalf("--checkpoint cannot be used with --limit")
+	}
+
+	if checkpointPath != "" && gzipOutput {
+		fatalf("--checkpoint cannot be used with --gzip")
+	}
+
+	if checkpointPath != "" && encryptOutput {
+		fatalf("--checkpoint cannot be used with --encrypt")
+	}
+
+	if nativeExportPath != "" && encryptOutput {
+		fatalf("--encrypt cannot be used with --native-export, which DynamoDB writes itself")
+	}
+
+	// The passphrase is checked before the export starts, rather than once
+	// the table has been scanned.
+	if encryptOutput {
+		_, err := readPassphrase()
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	if timeout < 0 {
+		fatalf("--timeout must not be negative")
+	}
+
+	// Cancel everything on the first interrupt so that an import stops
+	// between writes, but let any further interrupt kill the process.
+	interrupt, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt.Done()
+		stop()
+	}()
+
+	ctx := interrupt
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if convertPath != "" {
+		out, err := openOutput(ctx, aws.Config{}, "", outputPath)
+		if err != nil {
+			fatal(err)
+		}
+
+		err = convert(convertPath, convertFrom, convertTo, out)
+		if err != nil {
+			fatal(err)
+		}
+
+		err = out.Close()
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	}
+
+	cfg, err := loadConfig(ctx, region, profile, assumeRole)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	client := newClient(cfg)
+
+	if listOnly {
+		err := listTables(ctx, client, os.Stdout)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
 	}
 
-	client := dynamodb.NewFromConfig(cfg)
+	if validateOnly {
+		err := validateFile(ctx, cfg, importPath)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	}
 
 	if importPath != "" {
-		err := importFromFile(ctx, client, importPath)
+		err := importFromFile(ctx, client, cfg, importPath)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if putValue != "" {
+		err := putItem(ctx, client, putValue)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if describeOnly {
+		err := describe(ctx, client, tableName, os.Stdout)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if getKey != "" {
+		err := getItem(ctx, client, tableName, os.Stdout)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if countOnly {
+		n, err := scanSegments(ctx, client, tableName)
+		if err != nil {
+			fatal(err)
+		}
+
+		count, err := countItems(ctx, client, tableName, n)
+		if err != nil {
+			fatal(err)
+		}
+
+		fmt.Println(count)
+		os.Exit(0)
+	} else if nativeExportPath != "" {
+		err := nativeExport(ctx, client, tableName, nativeExportPath)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if copyTo != "" {
+		destination := client
+		if targetRegion != "" || targetProfile != "" || targetAssumeRole != "" {
+			targetCfg, err := loadConfig(ctx, cmp.Or(targetRegion, region), cmp.Or(targetProfile, profile), cmp.Or(targetAssumeRole, assumeRole))
+			if err != nil {
+				fatal(err)
+			}
+
+			destination = newClient(targetCfg)
+		}
+
+		err := copyTable(ctx, client, destination, tableName, copyTo)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if diffPath != "" {
+		err := diffFile(ctx, client, cfg, diffPath)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if deletePath != "" {
+		err := deleteFromFile(ctx, client, cfg, deletePath)
+		if err != nil {
+			fatal(err)
+		}
+
+		os.Exit(0)
+	} else if tables != "" {
+		err := exportTables(ctx, client, cfg, strings.Split(tables, ","))
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
 		}
 
 		os.Exit(0)
 	} else {
-		out, err := export(ctx, client)
+		out, err := openOutput(ctx, cfg, s3Path, outputPath)
+		if err != nil {
+			fatal(err)
+		}
+
+		_, err = export(ctx, client, cfg, tableName, out)
+		if err != nil {
+			fatal(err)
+		}
+
+		err = out.Close()
 		if err != nil {
-			log.Fatal(err)
+			fatal(err)
+		}
+
+		if checksum {
+			err = writeManifest(outputPath, out.files())
+			if err != nil {
+				fatal(err)
+			}
 		}
 
-		fmt.Println(out)
 		os.Exit(0)
 	}
 
@@ -79,103 +1134,102 @@ func main() {
 	os.Exit(1)
 }
 
-type exportFormat struct {
-	TableName  string
-	PrimaryKey string
-	RangeKey   string
+func knownFormat(name string) bool {
+	switch name {
+	case formatJSON, formatJSONL, formatCSV, formatDynamoDBJSON:
+		return true
+	}
 
-	Items []map[string]any
+	return false
 }
 
-func export(ctx context.Context, client *dynamodb.Client) (string, error) {
-	table, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
-		TableName: &tableName,
-	})
-	if err != nil {
-		return "", err
+// fatal exits with the error, explaining when it was caused by the timeout
+// or an interrupt.
+func fatal(err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		fatalf("timed out after %s: %s", timeout, err)
 	}
 
-	exportData := exportFormat{
-		TableName: *table.Table.TableName,
+	if errors.Is(err, context.Canceled) {
+		fatalf("interrupted")
 	}
 
-	for _, key := range table.Table.KeySchema {
-		if key.KeyType == types.KeyTypeHash {
-			exportData.PrimaryKey = *key.AttributeName
-		}
+	fatalf("%s", err)
+}
 
-		if key.KeyType == types.KeyTypeRange {
-			exportData.RangeKey = *key.AttributeName
-		}
+// loadConfig loads the AWS configuration, using the given region and named
+// profile unless they are empty. If a role is given, it is assumed using the
+// credentials from the rest of the configuration.
+func loadConfig(ctx context.Context, region, profile, role string) (aws.Config, error) {
+	mode, err := aws.ParseRetryMode(retryMode)
+	if err != nil {
+		return aws.Config{}, err
 	}
 
-	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{
-		TableName: &tableName,
-	})
-
-	var items []map[string]types.AttributeValue
-	for paginator.HasMorePages() {
-		output, err := paginator.NextPage(ctx)
-		if err != nil {
-			return "", err
-		}
-
-		items = append(items, output.Items...)
+	opts := []func(*config.LoadOptions) error{
+		config.WithRetryer(func() aws.Retryer {
+			return newRetryer(mode)
+		}),
 	}
 
-	err = attributevalue.UnmarshalListOfMaps(items, &exportData.Items)
-	if err != nil {
-		return "", err
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
 	}
 
-	dump, err := json.Marshal(exportData)
-	if err != nil {
-		return "", err
+	// Only the wait for a response is limited, rather than the whole
+	// request, so that reading a large file from S3 is not cut off.
+	if httpTimeout > 0 {
+		client := awshttp.NewBuildableClient().WithTransportOptions(func(transport *http.Transport) {
+			transport.ResponseHeaderTimeout = httpTimeout
+		})
+
+		opts = append(opts, config.WithHTTPClient(client))
 	}
 
-	return string(dump), err
-}
+	if profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
 
-func importFromFile(ctx context.Context, client *dynamodb.Client, path string) error {
-	raw, err := os.ReadFile(path)
-	if err != nil {
-		return err
+	if verbose {
+		opts = append(opts, config.WithLogger(sdkLogger{}), config.WithClientLogMode(aws.LogRetries))
 	}
 
-	var data exportFormat
-	err = json.Unmarshal(raw, &data)
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
-		return err
+		return aws.Config{}, err
 	}
 
-	var confirm bool
-	form := huh.NewForm(huh.NewGroup(
-		huh.NewConfirm().
-			Title(fmt.Sprintf("This will import data into %s! Do you want to continue?", tableName)).
-			Affirmative("yes").
-			Negative("no").
-			Value(&confirm),
-	))
-	form.Run()
+	if role != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = roleSessionName
+			if externalID != "" {
+				o.ExternalID = &externalID
+			}
+		})
 
-	if !confirm {
-		return nil
+		cfg.Credentials = aws.NewCredentialsCache(provider)
 	}
 
-	for _, item := range data.Items {
-		mapdata, err := attributevalue.MarshalMap(item)
-		if err != nil {
-			return err
+	return cfg, nil
+}
+
+func newRetryer(mode aws.RetryMode) aws.Retryer {
+	standard := func(o *retry.StandardOptions) {
+		o.MaxAttempts = maxRetries + 1
+
+		// Once the bucket is empty, requests fail rather than being retried,
+		// which stops a struggling table from being overwhelmed by retries.
+		o.RateLimiter = ratelimit.NewTokenRateLimit(retryTokenRate)
+		if retryTokenRate == 0 {
+			o.RateLimiter = ratelimit.None
 		}
+	}
 
-		_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
-			TableName: &tableName,
-			Item:      mapdata,
+	if mode == aws.RetryModeAdaptive {
+		return retry.NewAdaptiveMode(func(o *retry.AdaptiveModeOptions) {
+			o.StandardOptions = append(o.StandardOptions, standard)
 		})
-		if err != nil {
-			return err
-		}
 	}
 
-	return nil
+	return retry.NewStandard(standard)
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// nativeExportPollInterval is how often the progress of a native export is
+// checked. Exports take at least several minutes, so there is no point
+// checking more often.
+const nativeExportPollInterval = 30 * time.Second
+
+// nativeExport has DynamoDB export the table to S3 itself, from its point in
+// time recovery backups, and waits for the export to finish. This consumes
+// no read capacity, but the files are in AWS's own export format rather
+// than one of ours, so they are imported with --format dynamodb-json.
+func nativeExport(ctx context.Context, client dynamoDBAPI, name, s3URL string) error {
+	bucket, prefix, err := parseS3URL(s3URL)
+	if err != nil {
+		return err
+	}
+
+	table, err := describeTable(ctx, client, name)
+	if err != nil {
+		return err
+	}
+
+	output, err := client.ExportTableToPointInTime(ctx, &dynamodb.ExportTableToPointInTimeInput{
+		TableArn:     table.TableArn,
+		S3Bucket:     &bucket,
+		S3Prefix:     aws.String(strings.TrimSuffix(prefix, "/")),
+		ExportFormat: types.ExportFormatDynamodbJson,
+	})
+
+	var unavailable *types.PointInTimeRecoveryUnavailableException
+	if errors.As(err, &unavailable) {
+		return fmt.Errorf("point in time recovery must be enabled on %s for a native export: %w", name, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	arn := output.ExportDescription.ExportArn
+	log.Printf("started native export of %s, waiting for it to finish", name)
+
+	for {
+		select {
+		case <-ctx.Done():
+			warnf("stopped waiting, but the export will carry on: %s", aws.ToString(arn))
+			return ctx.Err()
+		case <-time.After(nativeExportPollInterval):
+		}
+
+		described, err := client.DescribeExport(ctx, &dynamodb.DescribeExportInput{ExportArn: arn})
+		if err != nil {
+			return err
+		}
+
+		description := described.ExportDescription
+
+		switch description.ExportStatus {
+		case types.ExportStatusCompleted:
+			successf("exported %d items from %s to s3://%s/%s", aws.ToInt64(description.ItemCount), name, bucket, aws.ToString(description.ExportManifest))
+			return nil
+		case types.ExportStatusFailed:
+			return fmt.Errorf("native export failed: %s: %s", aws.ToString(description.FailureCode), aws.ToString(description.FailureMessage))
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// maxSuggestions is how many similarly named tables are suggested when a
+// table does not exist.
+const maxSuggestions = 5
+
+// notFoundError replaces the opaque error from DynamoDB when a table does not
+// exist, which it still wraps so that it can be checked for.
+type notFoundError struct {
+	message string
+	err     error
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
+
+func (e *notFoundError) Unwrap() error {
+	return e.err
+}
+
+// tableNotFound explains that the table does not exist in the region, and
+// suggests tables with similar names in case it was a typo.
+func tableNotFound(ctx context.Context, client dynamoDBAPI, name string, err error) error {
+	message := fmt.Sprintf("table %q not found in region %s", name, client.Options().Region)
+
+	// The suggestions are only a hint, so failing to list the tables, such
+	// as without permission to, is not an error in itself.
+	suggestions, listErr := similarTables(ctx, client, name)
+	if listErr == nil && len(suggestions) > 0 {
+		message += fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, ", "))
+	}
+
+	return &notFoundError{message: message, err: err}
+}
+
+// similarTables returns the names of tables which are within a few edits of
+// the name, or contain it, closest first.
+func similarTables(ctx context.Context, client dynamoDBAPI, name string) ([]string, error) {
+	type match struct {
+		name     string
+		distance int
+	}
+
+	var matches []match
+
+	paginator := dynamodb.NewListTablesPaginator(client, &dynamodb.ListTablesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, table := range page.TableNames {
+			distance := editDistance(strings.ToLower(name), strings.ToLower(table))
+			if distance <= max(2, len(name)/3) || strings.Contains(strings.ToLower(table), strings.ToLower(name)) {
+				matches = append(matches, match{name: table, distance: distance})
+			}
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b match) int {
+		return a.distance - b.distance
+	})
+
+	var names []string
+	for _, m := range matches[:min(len(matches), maxSuggestions)] {
+		names = append(names, m.name)
+	}
+
+	return names, nil
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
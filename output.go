@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// output is where an export is written. It is made up of layers of writers,
+// such as compression on top of buffering, which all need to be flushed or
+// closed in order.
+type output struct {
+	io.Writer
+
+	// layers are ordered from the outermost writer to the destination.
+	layers []io.Writer
+
+	// written is the number of bytes written, before any compression.
+	written int64
+
+	// path is set when the export is written to a file. part is set when
+	// the export is split into numbered files of --split-size bytes each,
+	// where partStart is how much had been written before the current part.
+	path      string
+	part      int
+	partStart int64
+}
+
+// openOutput opens the destination of the export, which is an S3 object if
+// s3URL is set, a file if path is set, or stdout otherwise. When resuming
+// from a checkpoint, the output file is appended to rather than replaced.
+func openOutput(ctx context.Context, cfg aws.Config, s3URL, path string) (*output, error) {
+	if s3URL != "" {
+		w, err := newS3Writer(ctx, cfg, s3URL)
+		if err != nil {
+			return nil, err
+		}
+
+		return newOutput(w)
+	}
+
+	if path == "" {
+		return newOutput(os.Stdout)
+	}
+
+	if splitSize > 0 {
+		file, err := os.Create(partPath(path, 1))
+		if err != nil {
+			return nil, err
+		}
+
+		o, err := newOutput(file)
+		if err != nil {
+			return nil, err
+		}
+
+		o.path = path
+		o.part = 1
+
+		return o, nil
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if checkpointPath != "" {
+		if _, err := os.Stat(checkpointPath); err == nil {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+	}
+
+	file, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := newOutput(file)
+	if err != nil {
+		return nil, err
+	}
+
+	o.path = path
+
+	return o, nil
+}
+
+// outputExtension is the file extension for exports in the current format.
+func outputExtension() string {
+	extension := "." + format
+	if gzipOutput {
+		extension += ".gz"
+	}
+
+	if encryptOutput {
+		extension += ".enc"
+	}
+
+	return extension
+}
+
+// partPath numbers the path of a part of a split export, so that foo.jsonl
+// becomes foo.001.jsonl.
+func partPath(path string, part int) string {
+	extension := outputExtension()
+	if !strings.HasSuffix(path, extension) {
+		extension = filepath.Ext(path)
+	}
+
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(path, extension), part, extension)
+}
+
+func newOutput(dst io.WriteCloser) (*output, error) {
+	o := &output{
+		Writer: dst,
+		layers: []io.Writer{dst},
+	}
+
+	o.push(bufio.NewWriter(o.Writer))
+
+	// Compressed data is encrypted, rather than the other way around, since
+	// encrypted data does not compress.
+	if encryptOutput {
+		passphrase, err := readPassphrase()
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := newEncryptWriter(o.Writer, passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		o.push(w)
+	}
+
+	if gzipOutput {
+		o.push(gzip.NewWriter(o.Writer))
+	}
+
+	return o, nil
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	n, err := o.Writer.Write(p)
+	o.written += int64(n)
+	return n, err
+}
+
+func (o *output) push(w io.Writer) {
+	o.layers = append([]io.Writer{w}, o.layers...)
+	o.Writer = w
+}
+
+// full reports whether the current part of a split export has reached
+// --split-size.
+func (o *output) full() bool {
+	return o.part > 0 && o.written-o.partStart >= splitSize
+}
+
+// rotate closes the current part of a split export, and moves on to the
+// next.
+func (o *output) rotate() error {
+	err := o.Close()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(partPath(o.path, o.part+1))
+	if err != nil {
+		return err
+	}
+
+	next, err := newOutput(file)
+	if err != nil {
+		return err
+	}
+
+	next.path = o.path
+	next.part = o.part + 1
+	next.written = o.written
+	next.partStart = o.written
+
+	*o = *next
+	return nil
+}
+
+// files returns the paths of every file the export was written to.
+func (o *output) files() []string {
+	if o.part == 0 {
+		return []string{o.path}
+	}
+
+	var files []string
+	for part := 1; part <= o.part; part++ {
+		files = append(files, partPath(o.path, part))
+	}
+
+	return files
+}
+
+// Flush pushes everything written so far through to the destination.
+func (o *output) Flush() error {
+	for _, layer := range o.layers {
+		if f, ok := layer.(interface{ Flush() error }); ok {
+			err := f.Flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close finishes every layer, and then closes the destination.
+func (o *output) Close() error {
+	for _, layer := range o.layers {
+		var err error
+		switch l := layer.(type) {
+		case io.Closer:
+			err = l.Close()
+		case interface{ Flush() error }:
+			err = l.Flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
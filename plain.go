@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// These are the keys of the single-key objects used to represent values
+// which have no JSON equivalent in the plain formats, such as
+// {"$B": "aGVsbG8="} or {"$SS": ["a", "b"]}. Without them, binary values
+// would be imported as strings, and sets as lists.
+const (
+	binaryKey    = "$B"
+	stringSetKey = "$SS"
+	numberSetKey = "$NS"
+	binarySetKey = "$BS"
+)
+
+// plainItems converts items to the plain values used by the json, jsonl and
+// csv formats. Numbers are kept as json.Number so that no precision is lost.
+// Top-level attributes matching --exclude-attr are left out, since projection
+// expressions cannot match attributes by pattern.
+func plainItems(items []map[string]types.AttributeValue) []map[string]any {
+	plain := make([]map[string]any, len(items))
+	for i, item := range items {
+		plain[i] = plainItem(item)
+
+		for name := range plain[i] {
+			if excluded(name) {
+				delete(plain[i], name)
+			}
+		}
+	}
+
+	return plain
+}
+
+// excluded returns whether the attribute matches one of the --exclude-attr
+// patterns.
+func excluded(name string) bool {
+	for _, pattern := range excludeAttributes {
+		// The patterns were checked when the flag was parsed, so there
+		// can be no error.
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func plainItem(item map[string]types.AttributeValue) map[string]any {
+	plain := make(map[string]any, len(item))
+	for name, value := range item {
+		plain[name] = plainValue(value)
+	}
+
+	return plain
+}
+
+func plainValue(value types.AttributeValue) any {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return json.Number(v.Value)
+	case *types.AttributeValueMemberB:
+		return map[string]any{binaryKey: base64.StdEncoding.EncodeToString(v.Value)}
+	case *types.AttributeValueMemberBOOL:
+		return v.Value
+	case *types.AttributeValueMemberNULL:
+		return nil
+	case *types.AttributeValueMemberSS:
+		return map[string]any{stringSetKey: v.Value}
+	case *types.AttributeValueMemberNS:
+		numbers := make([]json.Number, len(v.Value))
+		for i, n := range v.Value {
+			numbers[i] = json.Number(n)
+		}
+		return map[string]any{numberSetKey: numbers}
+	case *types.AttributeValueMemberBS:
+		encoded := make([]string, len(v.Value))
+		for i, b := range v.Value {
+			encoded[i] = base64.StdEncoding.EncodeToString(b)
+		}
+		return map[string]any{binarySetKey: encoded}
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, element := range v.Value {
+			list[i] = plainValue(element)
+		}
+		return list
+	case *types.AttributeValueMemberM:
+		return plainItem(v.Value)
+	}
+
+	return nil
+}
+
+// marshalPlainItem converts an item read from one of the plain formats back
+// into attribute values, reversing plainItem.
+func marshalPlainItem(item map[string]any) (map[string]types.AttributeValue, error) {
+	marshaled := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		av, err := marshalPlainValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		marshaled[name] = av
+	}
+
+	return marshaled, nil
+}
+
+func marshalPlainValue(value any) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return &types.AttributeValueMemberN{Value: v.String()}, nil
+	case []any:
+		list := make([]types.AttributeValue, len(v))
+		for i, element := range v {
+			av, err := marshalPlainValue(element)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+
+			list[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: list}, nil
+	case map[string]any:
+		if typed, ok, err := marshalTypeHint(v); ok || err != nil {
+			return typed, err
+		}
+
+		m, err := marshalPlainItem(v)
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	}
+
+	return attributevalue.Marshal(value)
+}
+
+// marshalTypeHint converts the single-key objects used to represent values
+// which have no JSON equivalent, reporting false if the map is not one.
+func marshalTypeHint(m map[string]any) (types.AttributeValue, bool, error) {
+	if len(m) != 1 {
+		return nil, false, nil
+	}
+
+	if encoded, ok := m[binaryKey].(string); ok {
+		b, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, true, fmt.Errorf("%s: %w", binaryKey, err)
+		}
+
+		return &types.AttributeValueMemberB{Value: b}, true, nil
+	}
+
+	if elements, ok := m[stringSetKey].([]any); ok {
+		set := make([]string, len(elements))
+		for i, element := range elements {
+			s, ok := element.(string)
+			if !ok {
+				return nil, true, fmt.Errorf("%s: expected strings", stringSetKey)
+			}
+
+			set[i] = s
+		}
+
+		return &types.AttributeValueMemberSS{Value: set}, true, nil
+	}
+
+	if elements, ok := m[numberSetKey].([]any); ok {
+		set := make([]string, len(elements))
+		for i, element := range elements {
+			n, ok := element.(json.Number)
+			if !ok {
+				return nil, true, fmt.Errorf("%s: expected numbers", numberSetKey)
+			}
+
+			set[i] = n.String()
+		}
+
+		return &types.AttributeValueMemberNS{Value: set}, true, nil
+	}
+
+	if elements, ok := m[binarySetKey].([]any); ok {
+		set := make([][]byte, len(elements))
+		for i, element := range elements {
+			encoded, ok := element.(string)
+			if !ok {
+				return nil, true, fmt.Errorf("%s: expected base64 strings", binarySetKey)
+			}
+
+			b, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, true, fmt.Errorf("%s: %w", binarySetKey, err)
+			}
+
+			set[i] = b
+		}
+
+		return &types.AttributeValueMemberBS{Value: set}, true, nil
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// roundTrip exports the item in the json format and imports it again, the
+// same way an export and an import of the table would.
+func roundTrip(t *testing.T, item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	stream, err := newJSONStream(&buf, tableMetadata{TableName: "foo", PrimaryKey: "id"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, plain := range plainItems([]map[string]types.AttributeValue{item}) {
+		err = stream.write(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = stream.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := newJSONReader(&buf)
+	if err != nil {
+		t.Fatalf("reading %s: %s", buf.String(), err)
+	}
+
+	plain, err := reader.next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = reader.next()
+	if err != io.EOF {
+		t.Fatalf("expected a single item, got %v", err)
+	}
+
+	imported, err := marshalPlainItem(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return imported
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	// Bytes which are not valid UTF-8 are the ones which would be mangled
+	// by encoding them as a JSON string.
+	data := []byte{0x00, 0xff, 0xfe, 0x80, 'a', 0x7f, 0xc3}
+
+	imported := roundTrip(t, map[string]types.AttributeValue{
+		"id":   &types.AttributeValueMemberS{Value: "1"},
+		"data": &types.AttributeValueMemberB{Value: data},
+	})
+
+	b, ok := imported["data"].(*types.AttributeValueMemberB)
+	if !ok {
+		t.Fatalf("expected a binary attribute, got %T", imported["data"])
+	}
+
+	if !bytes.Equal(b.Value, data) {
+		t.Errorf("expected % x, got % x", data, b.Value)
+	}
+}
+
+func TestSetRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		set  types.AttributeValue
+	}{
+		{"SS", &types.AttributeValueMemberSS{Value: []string{"a", "b", "[1]"}}},
+		{"NS", &types.AttributeValueMemberNS{Value: []string{"1", "-2.5", "12345678901234567890123456789012345678"}}},
+		{"BS", &types.AttributeValueMemberBS{Value: [][]byte{{0x00, 0xff}, []byte("hello")}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			imported := roundTrip(t, map[string]types.AttributeValue{
+				"id":  &types.AttributeValueMemberS{Value: "1"},
+				"set": test.set,
+			})
+
+			// A set which came back as a list would have a different
+			// type, so comparing the values checks both.
+			if !reflect.DeepEqual(imported["set"], test.set) {
+				t.Errorf("expected %#v, got %#v", test.set, imported["set"])
+			}
+		})
+	}
+}
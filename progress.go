@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/mattn/go-isatty"
+)
+
+// progressBar renders a progress bar to stderr, so that it never ends up in
+// an export written to stdout. It does nothing when stderr is not a
+// terminal. A total of zero means the number of items is unknown, in which
+// case only the count and throughput are shown. It is safe to use from the
+// read loop and the batch writers at the same time.
+type progressBar struct {
+	mu      sync.Mutex
+	bar     progress.Model
+	total   int64
+	count   int64
+	started time.Time
+	enabled bool
+}
+
+func newProgressBar(total int64) *progressBar {
+	return &progressBar{
+		bar:     progress.New(progress.WithDefaultGradient(), progress.WithWidth(40)),
+		total:   total,
+		started: time.Now(),
+		enabled: isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()),
+	}
+}
+
+func (p *progressBar) add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.count += int64(n)
+	p.render()
+}
+
+// done renders the final state of the bar and moves on to a new line.
+func (p *progressBar) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.enabled {
+		return
+	}
+
+	// The total is often only an estimate, so make sure the bar finishes
+	// full even if fewer items were processed.
+	if p.total > 0 {
+		p.total = p.count
+	}
+
+	p.render()
+	fmt.Fprintln(os.Stderr)
+}
+
+func (p *progressBar) render() {
+	if !p.enabled {
+		return
+	}
+
+	rate := float64(p.count) / time.Since(p.started).Seconds()
+
+	if p.total == 0 {
+		fmt.Fprintf(os.Stderr, "\r%d items, %.0f items/s", p.count, rate)
+		return
+	}
+
+	percent := 1.0
+	if p.count < p.total {
+		percent = float64(p.count) / float64(p.total)
+	}
+
+	eta := "unknown"
+	if rate > 0 {
+		remaining := max(p.total-p.count, 0)
+		eta = (time.Duration(float64(remaining)/rate) * time.Second).String()
+	}
+
+	// Pad the line so that a shorter update fully overwrites the last one.
+	fmt.Fprintf(os.Stderr, "\r%s %d/%d items, %.0f items/s, ETA %-10s", p.bar.ViewAs(percent), p.count, p.total, rate, eta)
+}
+
+// logThroughput logs the average rate since the bar was started, such as to
+// compare runs with different --segments or --concurrency.
+func (p *progressBar) logThroughput(items int, capacity float64, kind string) {
+	elapsed := time.Since(p.started)
+	seconds := elapsed.Seconds()
+
+	message := fmt.Sprintf("%d items in %s, averaging %.1f items", items, elapsed.Round(100*time.Millisecond), float64(items)/seconds)
+	if capacity > 0 {
+		message += fmt.Sprintf(" and %.1f %s capacity units", capacity/seconds, kind)
+	}
+
+	log.Print(message + " per second")
+}
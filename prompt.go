@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+)
+
+// confirm asks the user to confirm before going ahead, unless --yes is set.
+// Without a terminal to ask on, it fails rather than waiting forever.
+func confirm(title string) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+
+	// The prompt falls back to the controlling terminal when STDIN is not
+	// one, such as when importing from STDIN.
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return false, errors.New("cannot ask for confirmation without a terminal, use --yes to continue without asking")
+		}
+		tty.Close()
+	}
+
+	var confirmed bool
+	form := huh.NewForm(huh.NewGroup(
+		huh.NewConfirm().
+			Title(title).
+			Affirmative("yes").
+			Negative("no").
+			Value(&confirmed),
+	))
+
+	err := form.Run()
+	if errors.Is(err, huh.ErrUserAborted) {
+		return false, nil
+	}
+
+	return confirmed, err
+}
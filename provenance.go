@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// withProvenance returns the metadata with the time of the export, and the
+// account and region it was taken from. The account is looked up with STS,
+// which a local endpoint such as DynamoDB Local does not have, so failing to
+// find it only warns rather than stopping the export.
+func withProvenance(ctx context.Context, cfg aws.Config, metadata tableMetadata) tableMetadata {
+	now := time.Now().UTC()
+
+	metadata.ExportedAt = &now
+	metadata.Region = cfg.Region
+
+	if endpointURL != "" {
+		return metadata
+	}
+
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		warnf("could not find the account ID to record in the export: %s", err)
+		return metadata
+	}
+
+	metadata.AccountID = aws.ToString(identity.Account)
+
+	return metadata
+}
+
+// provenance describes where and when the export was taken, or returns an
+// empty string for exports taken before this was recorded.
+func (m tableMetadata) provenance() string {
+	if m.ExportedAt == nil {
+		return ""
+	}
+
+	parts := []string{"exported from " + m.TableName}
+	if m.AccountID != "" {
+		parts = append(parts, "in account "+m.AccountID)
+	}
+
+	if m.Region != "" {
+		parts = append(parts, "in "+m.Region)
+	}
+
+	parts = append(parts, fmt.Sprintf("at %s", m.ExportedAt.Format(time.RFC3339)))
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// putItem imports the single item given as JSON by --put, in the same plain
+// format as the items of an export, going through the same checks and
+// confirmation as importing a file.
+func putItem(ctx context.Context, client dynamoDBAPI, value string) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.UseNumber()
+
+	var item map[string]any
+	err := decoder.Decode(&item)
+	if err != nil {
+		return fmt.Errorf("--put: %w", err)
+	}
+
+	if item == nil {
+		return fmt.Errorf("--put must be a JSON object")
+	}
+
+	return importItems(ctx, client, &singleItemReader{item: item}, "the item given by --put")
+}
+
+// singleItemReader reads just the one item.
+type singleItemReader struct {
+	item map[string]any
+	read bool
+}
+
+func (r *singleItemReader) metadata() tableMetadata {
+	return tableMetadata{}
+}
+
+func (r *singleItemReader) total() int64 {
+	return 1
+}
+
+func (r *singleItemReader) next() (map[string]any, error) {
+	if r.read {
+		return nil, io.EOF
+	}
+
+	r.read = true
+	return r.item, nil
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// itemReader yields the items of an import file one at a time. next returns
+// io.EOF once every item has been read. total returns zero if the number of
+// items is not known upfront.
+type itemReader interface {
+	metadata() tableMetadata
+	total() int64
+	next() (map[string]any, error)
+}
+
+// newItemReader returns a reader for data in the named format.
+func newItemReader(r io.Reader, name string) (itemReader, error) {
+	switch name {
+	case formatJSONL:
+		return newJSONLReader(r)
+	case formatCSV:
+		return newCSVReader(r)
+	case formatDynamoDBJSON:
+		return newDynamoDBJSONReader(r), nil
+	}
+
+	return newJSONReader(r)
+}
+
+// jsonReader reads the default json format, which has to be decoded in full
+// before any items are available. A bare array of items, such as from
+// another tool, is read too, but has none of the metadata.
+type jsonReader struct {
+	data  exportFormat
+	count int64
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	// The whole file is read first, so that errors can be reported with the
+	// line they are on.
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var reader jsonReader
+
+	if bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("[")) {
+		err = decoder.Decode(&reader.data.Items)
+		if err != nil {
+			return nil, jsonError(data, err, "Items.")
+		}
+	} else {
+		err = decoder.Decode(&reader.data)
+		if err != nil {
+			return nil, jsonError(data, err, "")
+		}
+
+		if !hasTableName(data) {
+			return nil, errors.New("missing TableName, so this does not look like an export, or a bare array of items")
+		}
+	}
+
+	// A null decodes to a nil item rather than failing like other values
+	// which are not objects.
+	for i, item := range reader.data.Items {
+		if item == nil {
+			return nil, fmt.Errorf("item %d is a JSON null, but every item must be an object", i)
+		}
+	}
+
+	reader.count = int64(len(reader.data.Items))
+
+	return &reader, nil
+}
+
+func (r *jsonReader) metadata() tableMetadata {
+	return r.data.tableMetadata
+}
+
+func (r *jsonReader) total() int64 {
+	return r.count
+}
+
+func (r *jsonReader) next() (map[string]any, error) {
+	if len(r.data.Items) == 0 {
+		return nil, io.EOF
+	}
+
+	item := r.data.Items[0]
+	r.data.Items = r.data.Items[1:]
+
+	return item, nil
+}
+
+// jsonError adds the line and column of a decoding error to it, and explains
+// the most likely mistake in the structure of the file. The prefix is added
+// to the path of the field in the error, which is Items. for bare arrays.
+func jsonError(data []byte, err error, prefix string) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	var offset int64
+	switch {
+	case errors.As(err, &syntaxError):
+		offset = syntaxError.Offset
+	case errors.As(err, &typeError):
+		offset = typeError.Offset
+		field := prefix + typeError.Field
+		if index, ok := strings.CutPrefix(field, "Items."); ok && index != "" {
+			err = fmt.Errorf("item %s is a JSON %s, but every item must be an object", index, typeError.Value)
+		} else if field == "Items" {
+			err = fmt.Errorf("Items is a JSON %s, but it must be an array of objects", typeError.Value)
+		}
+	default:
+		return err
+	}
+
+	data = data[:min(offset, int64(len(data)))]
+	line := bytes.Count(data, []byte("\n")) + 1
+	column := len(data) - bytes.LastIndexByte(data, '\n')
+
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+// readMetadataFile reads the metadata of a jsonl export written with
+// --metadata-file.
+func readMetadataFile(metadata *tableMetadata) error {
+	raw, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return err
+	}
+
+	if !hasTableName(raw) {
+		return fmt.Errorf("%s: missing TableName, so this does not look like the metadata of an export", metadataFile)
+	}
+
+	err = json.Unmarshal(raw, metadata)
+	if err != nil {
+		return fmt.Errorf("%s: %w", metadataFile, err)
+	}
+
+	return nil
+}
+
+// hasTableName reports whether the metadata includes a TableName. It may be
+// empty, such as when the file was converted from csv.
+func hasTableName(data []byte) bool {
+	var metadata struct {
+		TableName *string
+	}
+
+	return json.Unmarshal(data, &metadata) == nil && metadata.TableName != nil
+}
+
+// jsonlReader reads the jsonl format, decoding a single item at a time so
+// that the file never has to be held in memory. Each item is expected to be
+// on its own line, which is how errors are located.
+type jsonlReader struct {
+	decoder *json.Decoder
+	header  tableMetadata
+	line    int
+}
+
+func newJSONLReader(r io.Reader) (*jsonlReader, error) {
+	reader := jsonlReader{
+		decoder: json.NewDecoder(r),
+		line:    1,
+	}
+	reader.decoder.UseNumber()
+
+	// With --metadata-file or --no-metadata, every line is an item.
+	if metadataFile != "" || noMetadata {
+		if metadataFile != "" {
+			err := readMetadataFile(&reader.header)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		reader.line = 0
+
+		return &reader, nil
+	}
+
+	var header json.RawMessage
+	err := reader.decoder.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("line 1: %w", err)
+	}
+
+	if !hasTableName(header) {
+		return nil, errors.New("line 1: missing TableName, so this does not look like an export")
+	}
+
+	err = json.Unmarshal(header, &reader.header)
+	if err != nil {
+		return nil, fmt.Errorf("line 1: %w", err)
+	}
+
+	return &reader, nil
+}
+
+func (r *jsonlReader) metadata() tableMetadata {
+	return r.header
+}
+
+func (r *jsonlReader) total() int64 {
+	return 0
+}
+
+func (r *jsonlReader) next() (map[string]any, error) {
+	r.line++
+
+	var item map[string]any
+	err := r.decoder.Decode(&item)
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", r.line, err)
+	}
+
+	if item == nil {
+		return nil, fmt.Errorf("line %d: the item is a JSON null, but every item must be an object", r.line)
+	}
+
+	return item, nil
+}
+
+// partsReader reads the items of one or more files in turn, so that an
+// export split into parts can be imported in a single run. Each part is
+// only opened once the one before it has been read.
+type partsReader struct {
+	ctx   context.Context
+	cfg   aws.Config
+	paths []string
+
+	current itemReader
+	path    string
+	file    io.Closer
+	header  tableMetadata
+	count   int64
+}
+
+// openItemReader opens the data to import, which is a single file, S3 object
+// or STDIN as described by openImport, or every file matching a glob such
+// as 'foo.*.jsonl', or every file in a directory. Multiple files are read
+// in the order of their names.
+func openItemReader(ctx context.Context, cfg aws.Config, path string) (*partsReader, error) {
+	paths, err := importPaths(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &partsReader{
+		ctx:   ctx,
+		cfg:   cfg,
+		paths: paths,
+	}
+
+	err = reader.open()
+	if err != nil {
+		return nil, err
+	}
+
+	reader.header = reader.current.metadata()
+
+	if len(paths) == 1 {
+		reader.count = reader.current.total()
+	} else {
+		log.Printf("reading %d files from %s, in the order of their names", len(paths), path)
+	}
+
+	return reader, nil
+}
+
+func importPaths(path string) ([]string, error) {
+	if path == "-" || strings.HasPrefix(path, "s3://") {
+		return []string{path}, nil
+	}
+
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+
+		paths, err := withoutSidecars(matches)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no files match %s", path)
+		}
+
+		return paths, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	paths, err := withoutSidecars(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no files in %s", path)
+	}
+
+	return paths, nil
+}
+
+// withoutSidecars drops the files which are written next to the parts of an
+// export but are not parts themselves, which are the --checksum manifests
+// and the --metadata-file.
+func withoutSidecars(paths []string) ([]string, error) {
+	var metadata os.FileInfo
+	if metadataFile != "" {
+		var err error
+		metadata, err = os.Stat(metadataFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var parts []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, manifestPath("")) {
+			continue
+		}
+
+		if metadata != nil {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if os.SameFile(info, metadata) {
+				continue
+			}
+		}
+
+		parts = append(parts, path)
+	}
+
+	return parts, nil
+}
+
+// open opens the next part.
+func (r *partsReader) open() error {
+	path := r.paths[0]
+	r.paths = r.paths[1:]
+
+	file, err := openImport(r.ctx, r.cfg, path)
+	if err != nil {
+		return err
+	}
+
+	input, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	reader, err := newItemReader(input, format)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// The parts are imported as one, so they must all be from the table the
+	// first was exported from.
+	name := reader.metadata().TableName
+	if r.header.TableName != "" && name != "" && name != r.header.TableName {
+		file.Close()
+		return fmt.Errorf("%s was exported from %s, but the files before it were exported from %s", path, name, r.header.TableName)
+	}
+
+	r.current = reader
+	r.path = path
+	r.file = file
+
+	return nil
+}
+
+func (r *partsReader) metadata() tableMetadata {
+	return r.header
+}
+
+// total is only known when there is a single part, since the others are not
+// opened upfront.
+func (r *partsReader) total() int64 {
+	return r.count
+}
+
+func (r *partsReader) next() (map[string]any, error) {
+	for {
+		item, err := r.current.next()
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("%s: %w", r.path, err)
+		}
+
+		if err == nil || len(r.paths) == 0 {
+			return item, err
+		}
+
+		err = r.file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.open()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *partsReader) Close() error {
+	return r.file.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNullItems(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		_, err := newJSONReader(strings.NewReader(`{"TableName": "foo", "Items": [{"id": "1"}, null]}`))
+		if err == nil || !strings.Contains(err.Error(), "item 1 is a JSON null") {
+			t.Errorf("expected the null item to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		reader, err := newJSONLReader(strings.NewReader("{\"TableName\": \"foo\"}\n{\"id\": \"1\"}\nnull\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reader.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reader.next()
+		if err == nil || !strings.Contains(err.Error(), "line 3:") {
+			t.Errorf("expected the null item on line 3 to be rejected, got %v", err)
+		}
+	})
+}
+
+func TestImportPathsSkipsSidecars(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"foo.001.jsonl", "foo.002.jsonl", "foo.jsonl.sha256", "foo.metadata.json"} {
+		err := os.WriteFile(filepath.Join(dir, name), nil, 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previous := metadataFile
+	metadataFile = filepath.Join(dir, "foo.metadata.json")
+	t.Cleanup(func() { metadataFile = previous })
+
+	expected := []string{filepath.Join(dir, "foo.001.jsonl"), filepath.Join(dir, "foo.002.jsonl")}
+
+	for _, path := range []string{dir, filepath.Join(dir, "foo.*")} {
+		paths, err := importPaths(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(paths, expected) {
+			t.Errorf("expected %s to be just %v, got %v", path, expected, paths)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// progressFlushInterval is how often the keys written by an import are
+// flushed to the --progress-file.
+const progressFlushInterval = 5 * time.Second
+
+// importProgress records the key of every item an import has written, one
+// per line in the typed JSON format, so that an interrupted import can be run
+// again and skip them. It is safe for concurrent use.
+type importProgress struct {
+	mu      sync.Mutex
+	path    string
+	schema  tableMetadata
+	file    *os.File
+	w       *bufio.Writer
+	written map[string]bool
+	flushed time.Time
+	err     error
+}
+
+// openImportProgress reads the keys already written from the file at path,
+// if it exists, and opens it to record more.
+func openImportProgress(path string, schema tableMetadata) (*importProgress, error) {
+	p := &importProgress{
+		path:    path,
+		schema:  schema,
+		written: map[string]bool{},
+		flushed: time.Now(),
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxItemSize)
+	for scanner.Scan() {
+		p.written[scanner.Text()] = true
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	p.file = file
+	p.w = bufio.NewWriter(file)
+
+	return p, nil
+}
+
+// resuming returns the number of keys written by earlier runs.
+func (p *importProgress) resuming() int {
+	return len(p.written)
+}
+
+// done reports whether the item with the key was written by an earlier run.
+func (p *importProgress) done(key map[string]types.AttributeValue) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.written[typedJSON(key)]
+}
+
+// record adds the keys of the items to the file, which is flushed every
+// progressFlushInterval. The first error is kept and returned by close.
+func (p *importProgress) record(items ...map[string]types.AttributeValue) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, item := range items {
+		key := typedJSON(itemKeyAttributes(item, p.schema))
+		p.written[key] = true
+
+		if p.err == nil {
+			_, p.err = p.w.WriteString(key + "\n")
+		}
+	}
+
+	if p.err == nil && time.Since(p.flushed) >= progressFlushInterval {
+		p.err = p.w.Flush()
+		p.flushed = time.Now()
+	}
+}
+
+// close flushes the keys written so far, and closes the file. It can be
+// called more than once.
+func (p *importProgress) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.file == nil {
+		return p.err
+	}
+
+	if p.err == nil {
+		p.err = p.w.Flush()
+	}
+
+	err := p.file.Close()
+	p.file = nil
+
+	if p.err != nil {
+		return p.err
+	}
+
+	return err
+}
+
+// remove deletes the file once every item has been imported.
+func (p *importProgress) remove() error {
+	return os.Remove(p.path)
+}
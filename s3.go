@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// parseS3URL splits a URL such as s3://bucket/path/to/key into its bucket
+// and key.
+func parseS3URL(raw string) (string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", err
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != "s3" || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("%q is not an S3 URL of the form s3://bucket/key", raw)
+	}
+
+	return u.Host, key, nil
+}
+
+// s3Writer streams everything written to it into an S3 object. The upload
+// is split into multiple parts as needed, so the object can be larger than
+// available memory.
+type s3Writer struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func newS3Writer(ctx context.Context, cfg aws.Config, raw string) (*s3Writer, error) {
+	bucket, key, err := parseS3URL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	writer := &s3Writer{
+		PipeWriter: w,
+		done:       make(chan error, 1),
+	}
+
+	uploader := manager.NewUploader(s3.NewFromConfig(cfg))
+
+	go func() {
+		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket: &bucket,
+			Key:    &key,
+			Body:   r,
+		})
+
+		// Stop any further writes if the upload has failed.
+		r.CloseWithError(err)
+		writer.done <- err
+	}()
+
+	return writer, nil
+}
+
+// Close finishes the upload, and waits for it to complete.
+func (w *s3Writer) Close() error {
+	err := w.PipeWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	return <-w.done
+}
+
+// openS3Object streams the body of an S3 object.
+func openS3Object(ctx context.Context, cfg aws.Config, raw string) (io.ReadCloser, error) {
+	bucket, key, err := parseS3URL(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
+}
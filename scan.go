@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/dustin/go-humanize"
+	"golang.org/x/sync/errgroup"
+)
+
+// scanStats summarises a completed scan.
+type scanStats struct {
+	// segmentItems is the number of items returned by each segment.
+	segmentItems []int
+
+	// capacity is the read capacity units consumed, if the scan asked for
+	// them to be returned.
+	capacity float64
+}
+
+func (s scanStats) items() int {
+	var total int
+	for _, count := range s.segmentItems {
+		total += count
+	}
+
+	return total
+}
+
+type scanPage struct {
+	segment int
+	output  *dynamodb.ScanOutput
+}
+
+type scanOptions struct {
+	// segments is the number of segments to scan in parallel.
+	segments int
+
+	// checkpoint, if set, is where each segment starts scanning from, and is
+	// updated after every page has been handled.
+	checkpoint *checkpoint
+
+	// limit, if set, stops the scan once this many items have been
+	// returned.
+	limit int
+
+	// sampleRate, if set, is the probability of each item being kept, using
+	// a random number generator for each segment seeded from seed. Since the
+	// items in a segment are always returned in the same order, the same
+	// seed gives the same sample.
+	sampleRate float64
+	seed       uint64
+
+	// keyCondition, if set, queries a single partition of the table instead
+	// of scanning all of it. Queries cannot be split into segments.
+	keyCondition *keyCondition
+}
+
+// keyCondition is the key condition expression of a query, along with the
+// attribute names and values it refers to, which are added to those of the
+// scan.
+type keyCondition struct {
+	expression string
+	names      map[string]string
+	values     map[string]types.AttributeValue
+}
+
+// pager is the part of a scan or query paginator which scanPages uses.
+type pager interface {
+	HasMorePages() bool
+	NextPage(ctx context.Context, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// queryPager runs a query, returning its pages as if they were from a scan.
+type queryPager struct {
+	*dynamodb.QueryPaginator
+}
+
+func newQueryPager(client dynamoDBAPI, scan *dynamodb.ScanInput, condition *keyCondition) queryPager {
+	names := maps.Clone(scan.ExpressionAttributeNames)
+	values := maps.Clone(scan.ExpressionAttributeValues)
+
+	input := &dynamodb.QueryInput{
+		TableName:                 scan.TableName,
+		IndexName:                 scan.IndexName,
+		ConsistentRead:            scan.ConsistentRead,
+		FilterExpression:          scan.FilterExpression,
+		ProjectionExpression:      scan.ProjectionExpression,
+		Select:                    scan.Select,
+		Limit:                     scan.Limit,
+		ExclusiveStartKey:         scan.ExclusiveStartKey,
+		ReturnConsumedCapacity:    scan.ReturnConsumedCapacity,
+		KeyConditionExpression:    &condition.expression,
+		ExpressionAttributeNames:  mergeNames(names, condition.names),
+		ExpressionAttributeValues: values,
+	}
+
+	if input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{}
+	}
+
+	maps.Copy(input.ExpressionAttributeValues, condition.values)
+
+	return queryPager{dynamodb.NewQueryPaginator(client, input)}
+}
+
+func (p queryPager) NextPage(ctx context.Context, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	output, err := p.QueryPaginator.NextPage(ctx, optFns...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.ScanOutput{
+		Items:            output.Items,
+		Count:            output.Count,
+		ScannedCount:     output.ScannedCount,
+		LastEvaluatedKey: output.LastEvaluatedKey,
+		ConsumedCapacity: output.ConsumedCapacity,
+	}, nil
+}
+
+// scanPages runs the scan described by input, and calls fn with every page
+// of items returned. Pages arrive in no particular order, but fn is never
+// called concurrently, and scanPages only returns once every segment has
+// been fully drained or the limit has been reached. If fn returns an error,
+// the scan is stopped.
+func scanPages(ctx context.Context, client dynamoDBAPI, scan *dynamodb.ScanInput, opts scanOptions, fn func([]map[string]types.AttributeValue) error) (scanStats, error) {
+	segments := opts.segments
+	cp := opts.checkpoint
+
+	stats := scanStats{
+		segmentItems: make([]int, segments),
+	}
+
+	var inputs []*dynamodb.ScanInput
+	for segment := 0; segment < segments; segment++ {
+		input := *scan
+
+		if segments > 1 {
+			input.Segment = aws.Int32(int32(segment))
+			input.TotalSegments = aws.Int32(int32(segments))
+		}
+
+		if cp != nil {
+			key, done, err := cp.startKey(segment)
+			if err != nil {
+				return stats, err
+			}
+
+			if done {
+				continue
+			}
+
+			input.ExclusiveStartKey = key
+		}
+
+		inputs = append(inputs, &input)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g, ctx := errgroup.WithContext(ctx)
+	pages := make(chan scanPage)
+
+	for _, input := range inputs {
+		segment := int(aws.ToInt32(input.Segment))
+
+		g.Go(func() error {
+			var paginator pager = dynamodb.NewScanPaginator(client, input)
+			if opts.keyCondition != nil {
+				paginator = newQueryPager(client, input, opts.keyCondition)
+			}
+
+			for paginator.HasMorePages() {
+				output, err := paginator.NextPage(ctx)
+				if err != nil {
+					return err
+				}
+
+				select {
+				case pages <- scanPage{segment: segment, output: output}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			return nil
+		})
+	}
+
+	go func() {
+		g.Wait()
+		close(pages)
+	}()
+
+	samplers := make([]*rand.Rand, segments)
+	for segment := range samplers {
+		samplers[segment] = rand.New(rand.NewPCG(opts.seed, uint64(segment)))
+	}
+
+	var fnErr error
+	var stopped bool
+	for page := range pages {
+		if fnErr != nil || stopped {
+			continue
+		}
+
+		items := page.output.Items
+		count := int(page.output.Count)
+
+		if opts.sampleRate > 0 && opts.sampleRate < 1 {
+			items = sample(items, samplers[page.segment], opts.sampleRate)
+			count = len(items)
+		}
+
+		if opts.limit > 0 && stats.items()+count >= opts.limit {
+			count = opts.limit - stats.items()
+			if len(items) > count {
+				items = items[:count]
+			}
+
+			stopped = true
+		}
+
+		fnErr = fn(items)
+		stats.segmentItems[page.segment] += count
+
+		var units float64
+		if page.output.ConsumedCapacity != nil {
+			units = capacityUnits(*page.output.ConsumedCapacity)
+			stats.capacity += units
+		}
+
+		slog.Debug("scanned page", "table", aws.ToString(scan.TableName), "segment", page.segment, "items", count, "capacity", units)
+
+		if fnErr == nil && cp != nil {
+			fnErr = cp.update(page.segment, page.output.LastEvaluatedKey)
+		}
+
+		if fnErr != nil || stopped {
+			cancel()
+		}
+	}
+
+	err := g.Wait()
+
+	if fnErr != nil {
+		return stats, fnErr
+	}
+
+	if stopped {
+		return stats, nil
+	}
+
+	return stats, err
+}
+
+// sample keeps each item with the given probability.
+func sample(items []map[string]types.AttributeValue, r *rand.Rand, rate float64) []map[string]types.AttributeValue {
+	var kept []map[string]types.AttributeValue
+	for _, item := range items {
+		if r.Float64() < rate {
+			kept = append(kept, item)
+		}
+	}
+
+	return kept
+}
+
+const (
+	// bytesPerSegment is how much data each segment scans with --segments
+	// auto.
+	bytesPerSegment = 1 << 30
+
+	// maxAutoSegments is the most segments --segments auto picks, so that
+	// the largest tables do not start an unreasonable number of scans.
+	maxAutoSegments = 64
+)
+
+// scanSegments returns the number of segments to scan the table with, which
+// with --segments auto is one for every GB of data in the table according to
+// DescribeTable.
+func scanSegments(ctx context.Context, client dynamoDBAPI, name string) (int, error) {
+	if !autoSegments {
+		return segments, nil
+	}
+
+	table, err := describeTable(ctx, client, name)
+	if err != nil {
+		return 0, err
+	}
+
+	size := aws.ToInt64(table.TableSizeBytes)
+	n := min(max(1, int((size+bytesPerSegment-1)/bytesPerSegment)), maxAutoSegments)
+
+	log.Printf("scanning %s with %d segments, for %s and %d items", name, n, humanize.Bytes(uint64(size)), aws.ToInt64(table.ItemCount))
+	return n, nil
+}
+
+// countItems counts the items in the table with a scan which only returns the
+// number of items, rather than the items themselves.
+func countItems(ctx context.Context, client dynamoDBAPI, table string, segments int) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName: &table,
+		Select:    types.SelectCount,
+	}
+
+	stats, err := scanPages(ctx, client, input, scanOptions{segments: segments}, func([]map[string]types.AttributeValue) error {
+		return nil
+	})
+
+	return stats.items(), err
+}
+
+// keyProjection returns a projection expression which selects only the key
+// attributes of a table, along with the attribute names it refers to. The
+// names are always substituted in case they clash with reserved words.
+func keyProjection(metadata tableMetadata) (string, map[string]string) {
+	names := map[string]string{"#pk": metadata.PrimaryKey}
+	projection := "#pk"
+
+	if metadata.RangeKey != "" {
+		names["#rk"] = metadata.RangeKey
+		projection += ", #rk"
+	}
+
+	return projection, names
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// tableCreateTimeout is how long to wait for a new table to become active.
+const tableCreateTimeout = 10 * time.Minute
+
+// tableMetadata describes the table an export was taken from. In the jsonl
+// format it is written as the first line, ahead of the items. The schema is
+// recorded so that the table can be created again on import.
+type tableMetadata struct {
+	TableName  string
+	PrimaryKey string
+	RangeKey   string
+
+	KeySchema            []types.KeySchemaElement    `json:",omitempty"`
+	AttributeDefinitions []types.AttributeDefinition `json:",omitempty"`
+	BillingMode          types.BillingMode           `json:",omitempty"`
+
+	// ProvisionedThroughput is only recorded for provisioned tables.
+	ProvisionedThroughput *types.ProvisionedThroughput `json:",omitempty"`
+
+	GlobalSecondaryIndexes []secondaryIndex `json:",omitempty"`
+	LocalSecondaryIndexes  []secondaryIndex `json:",omitempty"`
+
+	TimeToLiveAttribute string                 `json:",omitempty"`
+	TimeToLiveStatus    types.TimeToLiveStatus `json:",omitempty"`
+
+	Tags map[string]string `json:",omitempty"`
+
+	// ExportedAt, AccountID and Region record when and where the export was
+	// taken, so that a backup can be traced back to its source.
+	ExportedAt *time.Time `json:",omitempty"`
+	AccountID  string     `json:",omitempty"`
+	Region     string     `json:",omitempty"`
+}
+
+type secondaryIndex struct {
+	IndexName             string
+	KeySchema             []types.KeySchemaElement
+	Projection            *types.Projection
+	ProvisionedThroughput *types.ProvisionedThroughput `json:",omitempty"`
+}
+
+// describeTable describes the table, explaining when it does not exist.
+func describeTable(ctx context.Context, client dynamoDBAPI, name string) (*types.TableDescription, error) {
+	output, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: &name,
+	})
+
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, tableNotFound(ctx, client, name, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Table, nil
+}
+
+func newTableMetadata(table *types.TableDescription) tableMetadata {
+	metadata := tableMetadata{
+		TableName:            *table.TableName,
+		KeySchema:            table.KeySchema,
+		AttributeDefinitions: table.AttributeDefinitions,
+		BillingMode:          types.BillingModeProvisioned,
+	}
+
+	// Tables which have always been provisioned may not have a billing mode
+	// summary at all.
+	if table.BillingModeSummary != nil && table.BillingModeSummary.BillingMode != "" {
+		metadata.BillingMode = table.BillingModeSummary.BillingMode
+	}
+
+	if metadata.BillingMode == types.BillingModeProvisioned {
+		metadata.ProvisionedThroughput = provisionedThroughput(table.ProvisionedThroughput)
+	}
+
+	for _, index := range table.GlobalSecondaryIndexes {
+		gsi := secondaryIndex{
+			IndexName:  *index.IndexName,
+			KeySchema:  index.KeySchema,
+			Projection: index.Projection,
+		}
+
+		if metadata.BillingMode == types.BillingModeProvisioned {
+			gsi.ProvisionedThroughput = provisionedThroughput(index.ProvisionedThroughput)
+		}
+
+		metadata.GlobalSecondaryIndexes = append(metadata.GlobalSecondaryIndexes, gsi)
+	}
+
+	for _, index := range table.LocalSecondaryIndexes {
+		metadata.LocalSecondaryIndexes = append(metadata.LocalSecondaryIndexes, secondaryIndex{
+			IndexName:  *index.IndexName,
+			KeySchema:  index.KeySchema,
+			Projection: index.Projection,
+		})
+	}
+
+	metadata.PrimaryKey, metadata.RangeKey = keyNames(table.KeySchema)
+
+	return metadata
+}
+
+// provisionedThroughput copies just the read and write capacity out of a
+// throughput description. On demand tables report zero capacity, so nil is
+// returned for them.
+func provisionedThroughput(throughput *types.ProvisionedThroughputDescription) *types.ProvisionedThroughput {
+	if throughput == nil || aws.ToInt64(throughput.ReadCapacityUnits) == 0 {
+		return nil
+	}
+
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  throughput.ReadCapacityUnits,
+		WriteCapacityUnits: throughput.WriteCapacityUnits,
+	}
+}
+
+// keyNames returns the names of the hash and range keys in a key schema.
+func keyNames(schema []types.KeySchemaElement) (string, string) {
+	var hashKey, rangeKey string
+	for _, key := range schema {
+		if key.KeyType == types.KeyTypeHash {
+			hashKey = *key.AttributeName
+		}
+
+		if key.KeyType == types.KeyTypeRange {
+			rangeKey = *key.AttributeName
+		}
+	}
+
+	return hashKey, rangeKey
+}
+
+// withIndex returns the metadata with the keys of the named secondary index
+// in place of the keys of the table, for exports of the index.
+func (m tableMetadata) withIndex(name string) (tableMetadata, error) {
+	for _, index := range slices.Concat(m.GlobalSecondaryIndexes, m.LocalSecondaryIndexes) {
+		if index.IndexName == name {
+			m.PrimaryKey, m.RangeKey = keyNames(index.KeySchema)
+			return m, nil
+		}
+	}
+
+	return tableMetadata{}, fmt.Errorf("%s has no index named %s", m.TableName, name)
+}
+
+// describeSchema returns the full metadata of the table, including the parts
+// of its configuration which DescribeTable does not return.
+func describeSchema(ctx context.Context, client dynamoDBAPI, table *types.TableDescription) (tableMetadata, error) {
+	metadata := newTableMetadata(table)
+
+	ttl, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: table.TableName,
+	})
+	if err != nil {
+		return tableMetadata{}, err
+	}
+
+	if description := ttl.TimeToLiveDescription; description != nil {
+		metadata.TimeToLiveAttribute = aws.ToString(description.AttributeName)
+		metadata.TimeToLiveStatus = description.TimeToLiveStatus
+	}
+
+	metadata.Tags, err = listTags(ctx, client, table.TableArn)
+	if err != nil {
+		return tableMetadata{}, err
+	}
+
+	return metadata, nil
+}
+
+// listTags returns the tags of the table, or nil if it has none.
+func listTags(ctx context.Context, client dynamoDBAPI, arn *string) (map[string]string, error) {
+	var tags map[string]string
+
+	input := &dynamodb.ListTagsOfResourceInput{ResourceArn: arn}
+	for {
+		output, err := client.ListTagsOfResource(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range output.Tags {
+			if tags == nil {
+				tags = map[string]string{}
+			}
+
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+
+		if output.NextToken == nil {
+			return tags, nil
+		}
+
+		input.NextToken = output.NextToken
+	}
+}
+
+// createTable creates the table from the schema recorded in the metadata,
+// and waits for it to become active. It does nothing if the table already
+// exists.
+func createTable(ctx context.Context, client dynamoDBAPI, name string, metadata tableMetadata) error {
+	// The table is described directly, since it not existing is expected.
+	_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: &name,
+	})
+	if err == nil {
+		warnf("table %s already exists, so it will not be created", name)
+		return nil
+	}
+
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	if len(metadata.KeySchema) == 0 {
+		return fmt.Errorf("cannot create %s, since the import does not include the table schema", name)
+	}
+
+	// Exports taken before the capacity was recorded only have the billing
+	// mode, so those tables are created on demand.
+	provisioned := metadata.BillingMode == types.BillingModeProvisioned && metadata.ProvisionedThroughput != nil
+	if metadata.BillingMode == types.BillingModeProvisioned && !provisioned {
+		warnf("%s used provisioned capacity, but its capacity is not recorded, so it will be created on demand", metadata.TableName)
+	}
+
+	keySchema := metadata.KeySchema
+
+	input := &dynamodb.CreateTableInput{
+		TableName:   &name,
+		KeySchema:   metadata.KeySchema,
+		BillingMode: types.BillingModePayPerRequest,
+	}
+
+	if provisioned {
+		input.BillingMode = types.BillingModeProvisioned
+		input.ProvisionedThroughput = metadata.ProvisionedThroughput
+	}
+
+	for _, index := range metadata.GlobalSecondaryIndexes {
+		gsi := types.GlobalSecondaryIndex{
+			IndexName:  aws.String(index.IndexName),
+			KeySchema:  index.KeySchema,
+			Projection: index.Projection,
+		}
+
+		// Every index of a provisioned table needs its own capacity, so
+		// indexes without it get the same as the table.
+		if provisioned {
+			gsi.ProvisionedThroughput = cmp.Or(index.ProvisionedThroughput, metadata.ProvisionedThroughput)
+		}
+
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, gsi)
+
+		keySchema = append(keySchema, index.KeySchema...)
+	}
+
+	for _, index := range metadata.LocalSecondaryIndexes {
+		input.LocalSecondaryIndexes = append(input.LocalSecondaryIndexes, types.LocalSecondaryIndex{
+			IndexName:  aws.String(index.IndexName),
+			KeySchema:  index.KeySchema,
+			Projection: index.Projection,
+		})
+
+		keySchema = append(keySchema, index.KeySchema...)
+	}
+
+	input.AttributeDefinitions = keyAttributeDefinitions(keySchema, metadata.AttributeDefinitions)
+
+	for key, value := range metadata.Tags {
+		// Tags such as aws:cloudformation:stack-name are reserved for AWS,
+		// and CreateTable rejects them.
+		if strings.HasPrefix(key, "aws:") {
+			continue
+		}
+
+		input.Tags = append(input.Tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	_, err = client.CreateTable(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("created table %s, waiting for it to become active", name)
+
+	err = dynamodb.NewTableExistsWaiter(client).Wait(ctx, &dynamodb.DescribeTableInput{
+		TableName: &name,
+	}, tableCreateTimeout)
+	if err != nil {
+		return err
+	}
+
+	return restoreTimeToLive(ctx, client, name, metadata)
+}
+
+// restoreTimeToLive enables TTL on the table if it was enabled on the
+// exported table.
+func restoreTimeToLive(ctx context.Context, client dynamoDBAPI, name string, metadata tableMetadata) error {
+	if metadata.TimeToLiveAttribute == "" {
+		return nil
+	}
+
+	if metadata.TimeToLiveStatus != types.TimeToLiveStatusEnabled && metadata.TimeToLiveStatus != types.TimeToLiveStatusEnabling {
+		return nil
+	}
+
+	_, err := client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: &name,
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: &metadata.TimeToLiveAttribute,
+			Enabled:       aws.Bool(true),
+		},
+	})
+
+	return err
+}
+
+// keyAttributeDefinitions returns only the definitions of attributes used in
+// the key schemas of the table and its indexes, since CreateTable rejects
+// definitions of any others.
+func keyAttributeDefinitions(keySchema []types.KeySchemaElement, definitions []types.AttributeDefinition) []types.AttributeDefinition {
+	used := map[string]bool{}
+	for _, key := range keySchema {
+		used[*key.AttributeName] = true
+	}
+
+	var filtered []types.AttributeDefinition
+	for _, definition := range definitions {
+		if used[*definition.AttributeName] {
+			filtered = append(filtered, definition)
+		}
+	}
+
+	return filtered
+}
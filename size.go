@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxItemSize is the largest item DynamoDB accepts, in bytes.
+const maxItemSize = 400 * 1024
+
+// itemSize approximates the size of an item in bytes, as DynamoDB calculates
+// it for capacity and item size limits.
+func itemSize(item map[string]types.AttributeValue) int {
+	var size int
+	for name, value := range item {
+		size += len(name) + attributeSize(value)
+	}
+
+	return size
+}
+
+func attributeSize(value types.AttributeValue) int {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return numberSize(v.Value)
+	case *types.AttributeValueMemberB:
+		return len(v.Value)
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		var size int
+		for _, s := range v.Value {
+			size += len(s)
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		var size int
+		for _, n := range v.Value {
+			size += numberSize(n)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		var size int
+		for _, b := range v.Value {
+			size += len(b)
+		}
+		return size
+	case *types.AttributeValueMemberL:
+		size := 3
+		for _, element := range v.Value {
+			size += 1 + attributeSize(element)
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		size := 3
+		for name, element := range v.Value {
+			size += 1 + len(name) + attributeSize(element)
+		}
+		return size
+	}
+
+	return 0
+}
+
+// numberSize is one byte per two significant digits, plus one byte.
+func numberSize(n string) int {
+	digits := strings.TrimLeft(n, "-+0")
+	if strings.Contains(digits, ".") {
+		digits = strings.TrimRight(strings.Replace(digits, ".", "", 1), "0")
+	}
+
+	return (len(digits)+1)/2 + 1
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rename is a top-level attribute renamed by --rename.
+type rename struct {
+	from, to string
+}
+
+// parseRename parses a --rename value of the form old=new.
+func parseRename(value string) (rename, error) {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok || from == "" || to == "" {
+		return rename{}, fmt.Errorf("%q is not of the form old=new", value)
+	}
+
+	return rename{from: from, to: to}, nil
+}
+
+// attributeDefault is a value given by --default for items which do not have
+// the attribute.
+type attributeDefault struct {
+	name  string
+	value any
+}
+
+// parseDefault parses a --default value of the form name=value, where the
+// name can be followed by the type of the value, such as count:N=0. Values
+// are strings unless a type is given.
+func parseDefault(value string) (attributeDefault, error) {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return attributeDefault{}, fmt.Errorf("%q is not of the form name=value or name:type=value", value)
+	}
+
+	name, kind, _ := strings.Cut(name, ":")
+
+	switch kind {
+	case "", "S":
+		return attributeDefault{name: name, value: raw}, nil
+	case "N":
+		_, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return attributeDefault{}, fmt.Errorf("%s: %q is not a number", name, raw)
+		}
+
+		return attributeDefault{name: name, value: json.Number(raw)}, nil
+	case "BOOL":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return attributeDefault{}, fmt.Errorf("%s: %q is not a boolean", name, raw)
+		}
+
+		return attributeDefault{name: name, value: b}, nil
+	}
+
+	return attributeDefault{}, fmt.Errorf("%s: unknown type %s, must be S, N or BOOL", name, kind)
+}
+
+// transformItem applies --drop, --rename, --default and then --transform to
+// an item read from an import file, before it is written. Transforms using
+// attributes the item does not have are skipped.
+func transformItem(item map[string]any) error {
+	for _, name := range dropAttributes {
+		delete(item, name)
+	}
+
+	for _, r := range renames {
+		value, ok := item[r.from]
+		if !ok {
+			continue
+		}
+
+		delete(item, r.from)
+		item[r.to] = value
+	}
+
+	for _, d := range defaults {
+		if _, ok := item[d.name]; !ok {
+			item[d.name] = d.value
+		}
+	}
+
+	for _, t := range transforms {
+		value, err := t.expr.eval(item)
+		if errors.Is(err, errMissingAttribute) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("--transform %s: %w", t.name, err)
+		}
+
+		item[t.name] = value
+	}
+
+	return nil
+}
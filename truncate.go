@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// truncate deletes every item in the table. Only the key attributes are
+// scanned, since they are all that is needed to delete an item, and the scan
+// is split into --segments feeding deletes to --concurrency batch writers, so
+// that large tables are emptied in parallel.
+func truncate(ctx context.Context, client dynamoDBAPI, table string) error {
+	description, err := describeTable(ctx, client, table)
+	if err != nil {
+		return err
+	}
+
+	metadata := newTableMetadata(description)
+	projection, names := keyProjection(metadata)
+
+	input := &dynamodb.ScanInput{
+		TableName:                &table,
+		ProjectionExpression:     &projection,
+		ExpressionAttributeNames: names,
+	}
+
+	n, err := scanSegments(ctx, client, table)
+	if err != nil {
+		return err
+	}
+
+	bar := newProgressBar(aws.ToInt64(description.ItemCount))
+
+	var deleted int
+	var capacity float64
+
+	writer := newBatchWriter(ctx, client, table, metadata, concurrency, func(n int, units float64) {
+		deleted += n
+		capacity += units
+		bar.add(n)
+	})
+	defer writer.close()
+
+	_, err = scanPages(ctx, client, input, scanOptions{segments: n}, func(page []map[string]types.AttributeValue) error {
+		for _, key := range page {
+			err := writer.add(types.WriteRequest{
+				DeleteRequest: &types.DeleteRequest{Key: key},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writer.close()
+	if err != nil {
+		return err
+	}
+
+	bar.done()
+
+	log.Printf("deleted %d existing items from %s", deleted, table)
+	bar.logThroughput(deleted, capacity, "write")
+
+	return nil
+}